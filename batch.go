@@ -0,0 +1,80 @@
+package GeeRPC
+
+import (
+	"codec/codec"
+	"context"
+	"reflect"
+	"sync"
+)
+
+// 批量请求中同时执行的调用数
+const defaultBatchParallelism = 8
+
+// 执行批量请求，各调用按BatchParallelism并行执行，全部结束后返回一个合并的响应
+// 批量请求只占用一个连接处理额度，每个调用各自经过限流与并发限制
+func (server *Server) handleBatch(sc *serverConn, h *codec.Header, items []codec.BatchItem, opt *Option) {
+	defer sc.wg.Done()
+	parallelism := server.BatchParallelism
+	if parallelism <= 0 {
+		parallelism = defaultBatchParallelism
+	}
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(item *codec.BatchItem) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if item.Header.Metadata == nil {
+				item.Header.Metadata = h.Metadata
+			}
+			body, err := server.callBatchItem(sc, item, opt)
+			item.Body = body
+			if err != nil {
+				setError(&item.Header, err)
+			}
+		}(&items[i])
+	}
+	wg.Wait()
+	sc.release()
+	server.sendResponse(sc.cc, &codec.Header{ServiceMethod: h.ServiceMethod, Seq: h.Seq, Frame: codec.FrameBatch}, items, &sc.sending)
+}
+
+// 执行批量请求中的单个调用，返回编码后的结果
+func (server *Server) callBatchItem(sc *serverConn, item *codec.BatchItem, opt *Option) ([]byte, error) {
+	svc, mtype, err := server.findService(item.Header.ServiceMethod)
+	if err != nil {
+		return nil, err
+	}
+	if mtype.kind != unaryMethod {
+		return nil, Errorf(CodeInvalidArgument, "rpc server: 流式方法%s不能批量调用", item.Header.ServiceMethod)
+	}
+	req := &request{h: &item.Header, svc: svc, mtype: mtype, argv: mtype.NewArgv(), replyv: mtype.newReplyv()}
+	argvi := req.argv.Interface()
+	if req.argv.Type().Kind() != reflect.Ptr {
+		argvi = req.argv.Addr().Interface()
+	}
+	if err := codec.DecodeBody(opt.CodecType, item.Body, argvi); err != nil {
+		return nil, Errorf(CodeInvalidArgument, "rpc服务读取请求体错误 %s", err)
+	}
+	//处理超时只限制等待并发额度的时间，方法开始执行后不会被中断
+	ctx, cancel := context.WithTimeout(context.Background(), opt.handleTimeout())
+	defer cancel()
+	release, err := server.admit(ctx, sc, req)
+	if err != nil {
+		return nil, err
+	}
+	err = svc.call(sc.callContext(ctx, req.h), mtype, req.argv, req.replyv)
+	release()
+	if err != nil {
+		return nil, err
+	}
+	body, err := codec.EncodeBody(opt.CodecType, req.replyv.Interface())
+	if err != nil {
+		return nil, Errorf(CodeInternal, "rpc server: 编码响应失败 %s", err)
+	}
+	return body, nil
+}
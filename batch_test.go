@@ -0,0 +1,80 @@
+package GeeRPC_test
+
+import (
+	GeeRPC "codec"
+	"codec/client"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBatch(t *testing.T) {
+	addr := startServer(t, nil, &Arith{})
+	c := dial(t, addr)
+	newCalls := func() []*client.Call {
+		return []*client.Call{
+			{ServiceMethod: "Arith.Add", Args: [2]int{1, 2}, Reply: new(int)},
+			{ServiceMethod: "Arith.Div", Args: [2]int{9, 3}, Reply: new(int)},
+			{ServiceMethod: "Arith.Div", Args: [2]int{1, 0}, Reply: new(int)},
+			{ServiceMethod: "Arith.Mul", Args: [2]int{1, 2}, Reply: new(int)},
+		}
+	}
+	want := []struct {
+		reply int
+		code  GeeRPC.Code
+		ok    bool
+	}{
+		{3, 0, true},
+		{3, 0, true},
+		{0, GeeRPC.CodeInvalidArgument, false},
+		{0, GeeRPC.CodeNotFound, false},
+	}
+	tests := []struct {
+		name string
+		do   func(context.Context, []*client.Call) error
+	}{
+		{"Batch", c.Batch},
+		{"CallBatch", c.CallBatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := newCalls()
+			if err := tt.do(context.Background(), calls); err != nil {
+				t.Fatal(err)
+			}
+			for i, call := range calls {
+				w := want[i]
+				if w.ok {
+					if call.Error != nil || *call.Reply.(*int) != w.reply {
+						t.Errorf("calls[%d] = %d, %v; want %d", i, *call.Reply.(*int), call.Error, w.reply)
+					}
+					continue
+				}
+				if GeeRPC.CodeOf(call.Error) != w.code {
+					t.Errorf("calls[%d].Error = %v, want code %s", i, call.Error, w.code)
+				}
+			}
+		})
+	}
+}
+
+func TestCallBatchTimeout(t *testing.T) {
+	addr := startServer(t, nil, &Arith{})
+	c := dial(t, addr)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	calls := []*client.Call{
+		{ServiceMethod: "Arith.Add", Args: [2]int{1, 2}, Reply: new(int)},
+		{ServiceMethod: "Arith.Sleep", Args: 200 * time.Millisecond, Reply: new(int)},
+	}
+	err := c.CallBatch(ctx, calls)
+	if !errors.Is(err, GeeRPC.ErrTimeout) {
+		t.Fatalf("err = %v, want timeout", err)
+	}
+	for i, call := range calls {
+		if call.Error != err {
+			t.Errorf("calls[%d].Error = %v, want the batch error", i, call.Error)
+		}
+	}
+}
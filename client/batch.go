@@ -0,0 +1,151 @@
+package client
+
+import (
+	GeeRPC "codec"
+	"codec/codec"
+	"context"
+	"sync/atomic"
+)
+
+// 批量调用，calls中只需设置ServiceMethod、Args与Reply
+// 所有请求在一次加锁内写入缓冲区并一次性写出，每个请求的错误写入call.Error
+// 全部完成时返回nil，ctx结束时未完成的请求以超时错误结束并返回该错误
+func (client *Client) Batch(ctx context.Context, calls []*Call) error {
+	done := make(chan *Call, len(calls))
+	md := GeeRPC.MetadataFromContext(ctx)
+	for _, call := range calls {
+		call.Seq, call.Error, call.Done, call.Metadata = 0, nil, done, md
+	}
+	client.sendBatch(ctx, calls)
+	for remaining := len(calls); remaining > 0; remaining-- {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			err := ctxError("客户端批量调用超时", ctx.Err())
+			for _, call := range calls {
+				if call.Seq != 0 && client.removeCall(call.Seq) != nil {
+					call.Error = err
+					atomic.AddUint64(&client.failed, 1)
+					remaining--
+				}
+			}
+			//其余请求已被接收协程取出，等待其写完结果
+			for ; remaining > 0; remaining-- {
+				<-done
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (client *Client) sendBatch(ctx context.Context, calls []*Call) {
+	client.sending.Lock()
+	defer client.sending.Unlock()
+	bw, _ := client.cc.(codec.BatchWriter)
+	flush := func() {
+		if bw != nil {
+			//写出失败时连接已关闭，接收协程会结束所有未完成的请求
+			_ = bw.Flush()
+		}
+	}
+	for _, call := range calls {
+		if client.inflight != nil && !client.inflight.TryAcquire() {
+			//额度不足时先写出已缓冲的请求，并在等待额度期间释放发送锁，使响应能够归还额度
+			flush()
+			client.sending.Unlock()
+			err := client.acquire(ctx)
+			client.sending.Lock()
+			if err != nil {
+				call.Error = err
+				client.finish(call)
+				continue
+			}
+		}
+		seq, err := client.registerCall(call)
+		if err != nil {
+			client.release(call)
+			call.Error = err
+			client.finish(call)
+			continue
+		}
+		client.header.ServiceMethod = call.ServiceMethod
+		client.header.Seq = seq
+		client.header.Error = ""
+		client.header.Frame = codec.FrameCall
+		client.header.Metadata = call.Metadata
+		if bw != nil {
+			err = bw.WriteBuffered(&client.header, call.Args)
+		} else {
+			err = client.cc.Write(&client.header, call.Args)
+		}
+		if err != nil {
+			if call := client.removeCall(seq); call != nil {
+				call.Error = transportError(err)
+				client.finish(call)
+			}
+		}
+	}
+	flush()
+}
+
+// 合并批量调用，calls中只需设置ServiceMethod、Args与Reply
+// 与Batch不同，所有调用放在一个请求帧中发送，服务端并行执行后返回一个合并的响应
+// 每个调用的错误写入call.Error，返回的error表示整个批量请求失败，此时每个call.Error都是该错误
+func (client *Client) CallBatch(ctx context.Context, calls []*Call) error {
+	err := client.callBatch(ctx, calls)
+	if err != nil {
+		for _, call := range calls {
+			call.Error = err
+		}
+	}
+	return err
+}
+
+func (client *Client) callBatch(ctx context.Context, calls []*Call) error {
+	items := make([]codec.BatchItem, len(calls))
+	for i, call := range calls {
+		body, err := codec.EncodeBody(client.opt.CodecType, call.Args)
+		if err != nil {
+			return GeeRPC.Errorf(GeeRPC.CodeInvalidArgument, "rpc客户端: 编码%s的参数失败 %s", call.ServiceMethod, err)
+		}
+		items[i] = codec.BatchItem{Header: codec.Header{ServiceMethod: call.ServiceMethod}, Body: body}
+	}
+	var results []codec.BatchItem
+	batch := &Call{
+		Args:     items,
+		Reply:    &results,
+		Done:     make(chan *Call, 1),
+		Metadata: GeeRPC.MetadataFromContext(ctx),
+		frame:    codec.FrameBatch,
+	}
+	if err := client.acquire(ctx); err != nil {
+		return err
+	}
+	client.send(batch)
+	select {
+	case <-ctx.Done():
+		if client.removeCall(batch.Seq) != nil {
+			atomic.AddUint64(&client.failed, 1)
+		}
+		return ctxError("客户端批量调用超时", ctx.Err())
+	case <-batch.Done:
+	}
+	if batch.Error != nil {
+		return batch.Error
+	}
+	if len(results) != len(calls) {
+		return GeeRPC.Errorf(GeeRPC.CodeInternal, "rpc客户端: 批量响应数量%d与请求数量%d不一致", len(results), len(calls))
+	}
+	for i, call := range calls {
+		call.Error = nil
+		if results[i].Header.Error != "" {
+			call.Error = serverError(&results[i].Header)
+			continue
+		}
+		if err := codec.DecodeBody(client.opt.CodecType, results[i].Body, call.Reply); err != nil {
+			call.Error = GeeRPC.Errorf(GeeRPC.CodeInternal, "reading body %s", err)
+		}
+	}
+	return nil
+}
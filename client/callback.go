@@ -0,0 +1,39 @@
+package client
+
+import (
+	GeeRPC "codec"
+	"codec/codec"
+	"codec/internal/callback"
+	"context"
+)
+
+// 注册供服务端通过Peer.Call回调的服务，方法签名与服务端相同
+func (client *Client) Register(rcvr interface{}) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.callback == nil {
+		client.callback = callback.NewHandler()
+	}
+	return client.callback.Register(rcvr)
+}
+
+// 处理服务端的回调请求，在接收协程中读取参数，执行结果异步写回
+func (client *Client) handleCallback(h *codec.Header) error {
+	client.mu.Lock()
+	handler := client.callback
+	client.mu.Unlock()
+	reply := func(rh *codec.Header, body interface{}) {
+		_ = client.sendFrame(rh, body)
+	}
+	if handler == nil {
+		if err := client.cc.ReadBody(nil); err != nil {
+			return err
+		}
+		rh := &codec.Header{ServiceMethod: h.ServiceMethod, Seq: h.Seq, Frame: codec.FrameCallbackReply}
+		rh.Error = "rpc client: no callback service registered"
+		rh.Code = uint32(GeeRPC.CodeNotFound)
+		go reply(rh, struct{}{})
+		return nil
+	}
+	return handler.Dispatch(context.Background(), h, client.cc.ReadBody, reply)
+}
@@ -4,6 +4,8 @@ import (
 	"bufio"
 	GeeRPC "codec"
 	"codec/codec"
+	"codec/flow"
+	"codec/internal/callback"
 	"context"
 	"encoding/json"
 	"errors"
@@ -14,6 +16,7 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -25,12 +28,16 @@ const (
 
 // 单个请求call
 type Call struct {
-	Seq           uint64      //请求编号
-	ServiceMethod string      //方法名+服务名
-	Args          interface{} //请求参数
-	Reply         interface{} //返回参数
-	Error         error       //错误提示
-	Done          chan *Call  //调用结束通道
+	Seq           uint64          //请求编号
+	ServiceMethod string          //方法名+服务名
+	Args          interface{}     //请求参数
+	Reply         interface{}     //返回参数
+	Error         error           //错误提示
+	Done          chan *Call      //调用结束通道
+	Metadata      GeeRPC.Metadata //随请求发送的元数据
+	stream        *Stream         //服务端流式调用的接收端
+	start         time.Time       //登记时间
+	frame         codec.FrameType //请求帧类型，批量请求为FrameBatch
 }
 
 func (call *Call) done() {
@@ -48,6 +55,13 @@ type Client struct {
 	pending  map[uint64]*Call //存储请求call
 	closing  bool             //是否关闭客户端
 	shutdown bool             //客户端异常关闭
+	inflight *flow.Window     //普通请求的发送额度，nil表示不限制
+	done     chan struct{}    //连接断开后关闭
+	callback callback.Handler //处理服务端回调的服务，Register时创建
+
+	bytes     *byteCounter //统计信息，原子操作
+	completed uint64
+	failed    uint64
 }
 type clientResult struct {
 	client *Client
@@ -59,7 +73,7 @@ type newClientFunc func(conn net.Conn, opt *GeeRPC.Option) (client *Client, err
 
 var _ io.Closer = (*Client)(nil)
 
-var ErrShutdown = errors.New("connection is shut down")
+var ErrShutdown error = &GeeRPC.Error{Code: GeeRPC.CodeUnavailable, Message: "connection is shut down", Retryable: true}
 
 // 关闭客户端
 func (client *Client) Close() error {
@@ -79,6 +93,13 @@ func (client *Client) IsAvailable() bool {
 	return !client.shutdown && !client.closing
 }
 
+// 未完成的请求数
+func (client *Client) Pending() int {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return len(client.pending)
+}
+
 // 注册call
 func (client *Client) registerCall(call *Call) (uint64, error) {
 	client.mu.Lock()
@@ -87,17 +108,49 @@ func (client *Client) registerCall(call *Call) (uint64, error) {
 		return 0, ErrShutdown
 	}
 	call.Seq = client.seq
+	call.start = time.Now()
 	client.pending[call.Seq] = call
 	client.seq++
 	return call.Seq, nil
 }
 
+// 获取普通请求的发送额度
+func (client *Client) acquire(ctx context.Context) error {
+	if client.inflight == nil {
+		return nil
+	}
+	if err := client.inflight.Acquire(ctx); err != nil {
+		if err == flow.ErrClosed {
+			return ErrShutdown
+		}
+		return ctxError("客户端等待发送额度超时", err)
+	}
+	return nil
+}
+
+// 普通请求离开pending时归还额度
+func (client *Client) release(call *Call) {
+	if client.inflight != nil && call.stream == nil {
+		client.inflight.Release(1)
+	}
+}
+
+// 获取请求call但不移除，用于流数据帧
+func (client *Client) getCall(seq uint64) *Call {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.pending[seq]
+}
+
 // 获取并移除请求call
 func (client *Client) removeCall(seq uint64) *Call {
 	client.mu.Lock()
 	defer client.mu.Unlock()
 	call := client.pending[seq]
 	delete(client.pending, seq)
+	if call != nil {
+		client.release(call)
+	}
 	return call
 }
 
@@ -108,9 +161,32 @@ func (client *Client) terminateCalls(err error) {
 	client.mu.Lock()
 	defer client.mu.Unlock()
 	client.shutdown = true
+	if client.inflight != nil {
+		client.inflight.Close()
+	}
+	err = transportError(err)
 	for _, call := range client.pending {
 		call.Error = err
-		call.done()
+		if call.stream != nil {
+			//唤醒等待发送额度的Send
+			call.stream.finish(err)
+		}
+		client.finish(call)
+	}
+	close(client.done)
+}
+
+// 连接断开或写出失败，包装为可重试的CodeUnavailable错误，errors.Is仍可判断底层错误
+func transportError(err error) error {
+	var e *GeeRPC.Error
+	if errors.As(err, &e) {
+		return err
+	}
+	return &GeeRPC.Error{
+		Code:      GeeRPC.CodeUnavailable,
+		Message:   "rpc客户端: 连接不可用 " + err.Error(),
+		Retryable: true,
+		Cause:     err,
 	}
 }
 
@@ -122,26 +198,69 @@ func (client *Client) receive() {
 		if err = client.cc.ReadHeader(&h); err != nil {
 			break
 		}
+		if h.Frame == codec.FrameStream {
+			call := client.getCall(h.Seq)
+			if call == nil || call.stream == nil {
+				err = client.cc.ReadBody(nil)
+				continue
+			}
+			err = call.stream.receive(client.cc)
+			continue
+		}
+		if h.Frame == codec.FrameWindow {
+			var n int
+			if err = client.cc.ReadBody(&n); err != nil {
+				break
+			}
+			if call := client.getCall(h.Seq); call != nil && call.stream != nil {
+				call.stream.window.Release(n)
+			}
+			continue
+		}
+		if h.Frame == codec.FrameCallback {
+			err = client.handleCallback(&h)
+			continue
+		}
 		call := client.removeCall(h.Seq)
 		switch {
 		case call == nil:
 			err = client.cc.ReadBody(nil)
 		case h.Error != "":
-			call.Error = fmt.Errorf(h.Error)
+			call.Error = serverError(&h)
 			err = client.cc.ReadBody(nil)
-			call.done()
+			client.finish(call)
 		default:
 			err = client.cc.ReadBody(call.Reply)
 			if err != nil {
-				call.Error = errors.New("reading body " + err.Error())
+				call.Error = GeeRPC.Errorf(GeeRPC.CodeInternal, "reading body %s", err)
 			}
-			call.done()
+			client.finish(call)
 		}
 
 	}
 	client.terminateCalls(err)
 }
 
+// 还原服务端错误，可用errors.Is(err, GeeRPC.ErrNotFound)等判断
+func serverError(h *codec.Header) error {
+	return &GeeRPC.Error{
+		Code:       GeeRPC.Code(h.Code),
+		Message:    h.Error,
+		Details:    h.Details,
+		Retryable:  h.Retryable,
+		RetryAfter: h.RetryAfter,
+	}
+}
+
+// ctx结束导致的错误
+func ctxError(msg string, err error) error {
+	code := GeeRPC.CodeCanceled
+	if errors.Is(err, context.DeadlineExceeded) {
+		code = GeeRPC.CodeTimeout
+	}
+	return GeeRPC.Errorf(code, "%s%s", msg, err)
+}
+
 // tcp初始化client
 func NewClient(conn net.Conn, opt *GeeRPC.Option) (*Client, error) {
 	f := codec.NewCodeFuncMap[opt.CodecType]
@@ -150,12 +269,16 @@ func NewClient(conn net.Conn, opt *GeeRPC.Option) (*Client, error) {
 		log.Println("rpc客户端:codec错误:", err)
 		return nil, err
 	}
-	if err := json.NewDecoder(conn).Decode(opt); err != nil {
+	bytes := &byteCounter{}
+	conn = &countingConn{Conn: conn, bytes: bytes}
+	if err := json.NewEncoder(conn).Encode(opt); err != nil {
 		log.Println("rpc客户端：opt错误 ", err)
 		_ = conn.Close()
 		return nil, err
 	}
-	return newClientCodec(f(conn), opt), nil
+	client := newClientCodec(f(conn), opt)
+	client.bytes = bytes
+	return client, nil
 }
 
 func newClientCodec(cc codec.Codec, opt *GeeRPC.Option) *Client {
@@ -163,7 +286,12 @@ func newClientCodec(cc codec.Codec, opt *GeeRPC.Option) *Client {
 		seq:     1, // 编号初始值为1
 		cc:      cc,
 		opt:     opt,
+		bytes:   &byteCounter{},
 		pending: make(map[uint64]*Call),
+		done:    make(chan struct{}),
+	}
+	if opt.MaxInflight > 0 {
+		client.inflight = flow.NewWindow(opt.MaxInflight)
 	}
 	go client.receive()
 	return client
@@ -178,11 +306,16 @@ func parseOptions(opts ...*GeeRPC.Option) (*GeeRPC.Option, error) {
 	if len(opts) != 1 {
 		return nil, errors.New("number of options is more than 1")
 	}
-	opt := opts[0]
+	//复制一份再补全默认值，调用方的Option可能被多个并发拨号共用
+	o := *opts[0]
+	opt := &o
 	opt.MagicNumber = GeeRPC.DefaultOption.MagicNumber
 	if opt.CodecType == "" {
 		opt.CodecType = GeeRPC.DefaultOption.CodecType
 	}
+	if opt.StreamWindow <= 0 {
+		opt.StreamWindow = GeeRPC.DefaultOption.StreamWindow
+	}
 	return opt, nil
 }
 
@@ -266,8 +399,9 @@ func (client *Client) send(call *Call) {
 	//客户端注册call
 	seq, err := client.registerCall(call)
 	if err != nil {
+		client.release(call)
 		call.Error = err
-		call.done()
+		client.finish(call)
 		return
 	}
 
@@ -275,18 +409,35 @@ func (client *Client) send(call *Call) {
 	client.header.ServiceMethod = call.ServiceMethod
 	client.header.Seq = seq
 	client.header.Error = ""
+	client.header.Frame = call.frame
+	client.header.Metadata = call.Metadata
 
 	//发送消息
 	if err := client.cc.Write(&client.header, call.Args); err != nil {
 		call := client.removeCall(seq)
 		if call != nil {
-			call.Error = err
-			call.done()
+			call.Error = transportError(err)
+			client.finish(call)
 		}
 	}
 }
 
+// 发送控制帧，不登记call
+func (client *Client) sendFrame(h *codec.Header, body interface{}) error {
+	client.sending.Lock()
+	defer client.sending.Unlock()
+	if err := client.cc.Write(h, body); err != nil {
+		return transportError(err)
+	}
+	return nil
+}
+
+// 异步调用，连接额度耗尽时阻塞直到有请求完成
 func (client *Client) Go(serviceMethod string, args, reply interface{}, done chan *Call) *Call {
+	return client.goCall(context.Background(), serviceMethod, args, reply, done)
+}
+
+func (client *Client) goCall(ctx context.Context, serviceMethod string, args, reply interface{}, done chan *Call) *Call {
 	if done == nil {
 		done = make(chan *Call, 10)
 	} else if cap(done) == 0 {
@@ -297,20 +448,43 @@ func (client *Client) Go(serviceMethod string, args, reply interface{}, done cha
 		Args:          args,
 		Reply:         reply,
 		Done:          done,
+		Metadata:      GeeRPC.MetadataFromContext(ctx),
+	}
+	if err := client.acquire(ctx); err != nil {
+		call.Error = err
+		client.finish(call)
+		return call
 	}
 	client.send(call)
 	return call
 }
 
 func (client *Client) Call(ctx context.Context, serviceMethod string, args, reply interface{}) error {
-	call := <-client.Go(serviceMethod, args, reply, make(chan *Call, 1)).Done
+	call := client.goCall(ctx, serviceMethod, args, reply, make(chan *Call, 1))
 	select {
 	case <-ctx.Done():
-		client.removeCall(call.Seq)
-		return errors.New("客户端调用方法超时" + ctx.Err().Error())
+		if client.removeCall(call.Seq) != nil {
+			atomic.AddUint64(&client.failed, 1)
+		}
+		return ctxError("客户端调用方法超时", ctx.Err())
 	case call := <-call.Done:
 		return call.Error
 	}
+}
 
-	return call.Error
+// 单向调用，请求写出后立即返回，服务端执行方法但不发送响应
+// 不占用pending与发送额度，返回的error只表示请求是否写出
+func (client *Client) Notify(ctx context.Context, serviceMethod string, args interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return ctxError("客户端单向调用已取消", err)
+	}
+	if !client.IsAvailable() {
+		return ErrShutdown
+	}
+	h := &codec.Header{
+		ServiceMethod: serviceMethod,
+		Metadata:      GeeRPC.MetadataFromContext(ctx),
+		NoReply:       true,
+	}
+	return client.sendFrame(h, args)
 }
@@ -0,0 +1,93 @@
+package client_test
+
+import (
+	GeeRPC "codec"
+	"codec/client"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// 服务端读完Option后断开连接，模拟后端掉线
+func TestConnectionDropIsUnavailable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		buf := make([]byte, 256)
+		_, _ = conn.Read(buf)
+		time.Sleep(50 * time.Millisecond)
+		_ = conn.Close()
+	}()
+	c, err := client.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var reply int
+	err = c.Call(ctx, "Foo.Sum", 1, &reply)
+	if !errors.Is(err, GeeRPC.ErrUnavailable) || !GeeRPC.IsRetryable(err) {
+		t.Fatalf("err = %v (code %s), want retryable unavailable", err, GeeRPC.CodeOf(err))
+	}
+	var e *GeeRPC.Error
+	if !errors.As(err, &e) || e.Cause == nil {
+		t.Fatalf("err = %v, want the transport error as cause", err)
+	}
+}
+
+// 连接断开时，等待发送额度的Send应返回连接错误
+func TestStreamSendUnblocksOnDisconnect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	closeConn := make(chan struct{})
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		//读取但不处理任何请求，发送额度不会归还
+		go func() { _, _ = io.Copy(io.Discard, conn) }()
+		<-closeConn
+		_ = conn.Close()
+	}()
+	c, err := client.Dial("tcp", l.Addr().String(), &GeeRPC.Option{StreamWindow: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	var reply int
+	st := c.ClientStream(context.Background(), "Foo.Sum", &reply)
+	sent := make(chan error, 1)
+	go func() {
+		for {
+			if err := st.Send(1); err != nil {
+				sent <- err
+				return
+			}
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(closeConn)
+	select {
+	case err := <-sent:
+		if !errors.Is(err, GeeRPC.ErrUnavailable) {
+			t.Fatalf("Send err = %v, want unavailable", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send still blocked after the connection dropped")
+	}
+}
@@ -0,0 +1,17 @@
+package client
+
+import (
+	GeeRPC "codec"
+	"context"
+)
+
+// 订阅服务端的主题，每条事件写入ev，取消ctx结束订阅
+//
+//	var ev GeeRPC.Event
+//	st := client.Subscribe(ctx, "orders", &ev)
+//	for st.Next() {
+//		// 使用 ev
+//	}
+func (client *Client) Subscribe(ctx context.Context, topic string, ev *GeeRPC.Event) *Stream {
+	return client.Stream(ctx, GeeRPC.PubSubSubscribe, GeeRPC.SubscribeRequest{Topic: topic}, ev)
+}
@@ -0,0 +1,229 @@
+package client
+
+import (
+	GeeRPC "codec"
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// 自动重连客户端的连接状态
+type ConnState int
+
+const (
+	StateConnecting       ConnState = iota //正在建立连接
+	StateReady                             //连接可用
+	StateTransientFailure                  //连接失败，等待退避后重连
+	StateShutdown                          //已关闭
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateConnecting:
+		return "CONNECTING"
+	case StateReady:
+		return "READY"
+	case StateTransientFailure:
+		return "TRANSIENT_FAILURE"
+	case StateShutdown:
+		return "SHUTDOWN"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// 重连策略
+type ReconnectPolicy struct {
+	BaseBackoff   time.Duration   //第一次重连前的退避上限，之后每次翻倍
+	MaxBackoff    time.Duration   //退避上限
+	StableTime    time.Duration   //连接保持超过该时间才重置退避，为0时取1秒
+	WaitForReady  bool            //断线期间新的调用等待重连成功（受ctx限制），false时立即返回ErrDisconnected
+	OnStateChange func(ConnState) //状态变化回调，在重连协程中调用，不应阻塞
+}
+
+// 默认重连策略
+var DefaultReconnectPolicy = &ReconnectPolicy{
+	BaseBackoff:  time.Millisecond * 100,
+	MaxBackoff:   time.Second * 10,
+	StableTime:   time.Second,
+	WaitForReady: true,
+}
+
+var ErrDisconnected error = &GeeRPC.Error{Code: GeeRPC.CodeUnavailable, Message: "connection is reconnecting", Retryable: true}
+
+// 自动重连客户端，连接断开后按指数退避重新拨号并重新握手
+// 断开时未完成的调用以CodeUnavailable错误结束，不会自动重发
+type ReconnectingClient struct {
+	rpcAddr string
+	opts    []*GeeRPC.Option
+	p       *ReconnectPolicy
+
+	mu     sync.Mutex
+	client *Client
+	state  ConnState
+	ready  chan struct{} //连接可用时关闭，断开后替换
+	closed chan struct{}
+}
+
+// 创建自动重连客户端，rpcAddr格式同XDial，在后台建立连接，p为nil时使用默认策略
+func NewReconnectingClient(rpcAddr string, p *ReconnectPolicy, opts ...*GeeRPC.Option) *ReconnectingClient {
+	if p == nil {
+		p = DefaultReconnectPolicy
+	}
+	rc := &ReconnectingClient{
+		rpcAddr: rpcAddr,
+		opts:    opts,
+		p:       p,
+		state:   StateConnecting,
+		ready:   make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	go rc.run()
+	return rc
+}
+
+// 当前连接状态
+func (rc *ReconnectingClient) State() ConnState {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.state
+}
+
+func (rc *ReconnectingClient) setState(state ConnState) {
+	rc.mu.Lock()
+	if rc.state == state || rc.state == StateShutdown {
+		rc.mu.Unlock()
+		return
+	}
+	rc.state = state
+	rc.mu.Unlock()
+	if rc.p.OnStateChange != nil {
+		rc.p.OnStateChange(state)
+	}
+}
+
+// 第attempt次重连前的退避时间，指数增长并全量抖动
+func (p *ReconnectPolicy) backoff(attempt int) time.Duration {
+	d := p.BaseBackoff << uint(attempt)
+	if d <= 0 || (p.MaxBackoff > 0 && d > p.MaxBackoff) {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+func (p *ReconnectPolicy) stableTime() time.Duration {
+	if p.StableTime > 0 {
+		return p.StableTime
+	}
+	return time.Second
+}
+
+// 每次重连前都先退避，连接断开后同样如此
+// 握手成功后立即断开（如对端过载或代理重启）时退避继续增长，避免不断重连
+func (rc *ReconnectingClient) run() {
+	for attempt := 0; ; attempt++ {
+		rc.setState(StateConnecting)
+		if client, err := XDial(rc.rpcAddr, rc.opts...); err == nil {
+			start := time.Now()
+			if !rc.serve(client) {
+				return
+			}
+			if time.Since(start) >= rc.p.stableTime() {
+				attempt = 0
+			}
+		}
+		rc.setState(StateTransientFailure)
+		t := time.NewTimer(rc.p.backoff(attempt))
+		select {
+		case <-rc.closed:
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// 使用连接直到断开，客户端关闭时返回false
+func (rc *ReconnectingClient) serve(client *Client) bool {
+	rc.mu.Lock()
+	select {
+	case <-rc.closed:
+		rc.mu.Unlock()
+		_ = client.Close()
+		return false
+	default:
+	}
+	rc.client = client
+	close(rc.ready)
+	rc.mu.Unlock()
+	rc.setState(StateReady)
+	select {
+	case <-rc.closed:
+		return false
+	case <-client.done:
+	}
+	rc.mu.Lock()
+	rc.client = nil
+	rc.ready = make(chan struct{})
+	rc.mu.Unlock()
+	return true
+}
+
+// 取出可用的连接，断线时按策略等待或立即失败
+func (rc *ReconnectingClient) get(ctx context.Context) (*Client, error) {
+	for {
+		rc.mu.Lock()
+		client, ready := rc.client, rc.ready
+		rc.mu.Unlock()
+		select {
+		case <-rc.closed:
+			return nil, ErrShutdown
+		default:
+		}
+		if client != nil {
+			return client, nil
+		}
+		if !rc.p.WaitForReady {
+			return nil, ErrDisconnected
+		}
+		select {
+		case <-rc.closed:
+			return nil, ErrShutdown
+		case <-ctx.Done():
+			return nil, ctxError("客户端等待重连超时", ctx.Err())
+		case <-ready:
+		}
+	}
+}
+
+func (rc *ReconnectingClient) Call(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	client, err := rc.get(ctx)
+	if err != nil {
+		return err
+	}
+	return client.Call(ctx, serviceMethod, args, reply)
+}
+
+// 关闭客户端并停止重连
+func (rc *ReconnectingClient) Close() error {
+	rc.mu.Lock()
+	select {
+	case <-rc.closed:
+		rc.mu.Unlock()
+		return ErrShutdown
+	default:
+	}
+	close(rc.closed)
+	client := rc.client
+	rc.client = nil
+	rc.mu.Unlock()
+	rc.setState(StateShutdown)
+	if client != nil {
+		return client.Close()
+	}
+	return nil
+}
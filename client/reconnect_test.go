@@ -0,0 +1,212 @@
+package client_test
+
+import (
+	GeeRPC "codec"
+	"codec/client"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 握手后立即断开的服务端不应导致重连风暴
+func TestReconnectBacksOffAfterDisconnect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	var accepted int64
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt64(&accepted, 1)
+			_ = conn.Close()
+		}
+	}()
+	var changes int64
+	rc := client.NewReconnectingClient("tcp@"+l.Addr().String(), &client.ReconnectPolicy{
+		BaseBackoff:   100 * time.Millisecond,
+		MaxBackoff:    time.Second,
+		OnStateChange: func(client.ConnState) { atomic.AddInt64(&changes, 1) },
+	})
+	time.Sleep(300 * time.Millisecond)
+	_ = rc.Close()
+	//退避上限依次为100ms、200ms、400ms…，全量抖动下300ms内也只会重连几次
+	if n := atomic.LoadInt64(&accepted); n > 10 {
+		t.Fatalf("%d connections in 300ms, want backoff between redials", n)
+	}
+	if n := atomic.LoadInt64(&changes); n > 40 {
+		t.Fatalf("%d state changes in 300ms", n)
+	}
+}
+
+type Echo struct{}
+
+func (Echo) Echo(n int, reply *int) error {
+	*reply = n
+	return nil
+}
+
+func (Echo) Sleep(d time.Duration, reply *int) error {
+	time.Sleep(d)
+	return nil
+}
+
+// 可以停止与重启的服务端，停止时断开所有连接，重启后监听同一地址
+type restartableServer struct {
+	t      *testing.T
+	server *GeeRPC.Server
+	addr   string
+	mu     sync.Mutex
+	l      net.Listener
+	conns  []net.Conn
+}
+
+func newRestartableServer(t *testing.T) *restartableServer {
+	s := &restartableServer{t: t, server: GeeRPC.NewServer(), addr: "127.0.0.1:0"}
+	if err := s.server.Register(Echo{}); err != nil {
+		t.Fatal(err)
+	}
+	s.start()
+	t.Cleanup(s.stop)
+	return s
+}
+
+func (s *restartableServer) start() {
+	l, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.l, s.addr = l, l.Addr().String()
+	s.mu.Unlock()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			go s.server.ServeConn(conn)
+		}
+	}()
+}
+
+func (s *restartableServer) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.l != nil {
+		_ = s.l.Close()
+		s.l = nil
+	}
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+}
+
+func waitState(t *testing.T, rc *client.ReconnectingClient, want client.ConnState) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for rc.State() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("state = %s, want %s", rc.State(), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReconnectingClient(t *testing.T) {
+	s := newRestartableServer(t)
+	var mu sync.Mutex
+	var states []client.ConnState
+	rc := client.NewReconnectingClient("tcp@"+s.addr, &client.ReconnectPolicy{
+		BaseBackoff:  10 * time.Millisecond,
+		MaxBackoff:   50 * time.Millisecond,
+		WaitForReady: true,
+		OnStateChange: func(state client.ConnState) {
+			mu.Lock()
+			states = append(states, state)
+			mu.Unlock()
+		},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var reply int
+	if err := rc.Call(ctx, "Echo.Echo", 1, &reply); err != nil || reply != 1 {
+		t.Fatalf("Echo(1) = %d, %v", reply, err)
+	}
+
+	//服务端重启期间的调用等待重连成功
+	s.stop()
+	waitState(t, rc, client.StateTransientFailure)
+	done := make(chan error, 1)
+	go func() {
+		var reply int
+		done <- rc.Call(ctx, "Echo.Echo", 2, &reply)
+	}()
+	time.Sleep(30 * time.Millisecond)
+	s.start()
+	if err := <-done; err != nil {
+		t.Fatalf("call during restart: %v", err)
+	}
+	waitState(t, rc, client.StateReady)
+
+	if err := rc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.Call(ctx, "Echo.Echo", 3, &reply); !errors.Is(err, client.ErrShutdown) {
+		t.Fatalf("call after Close: %v, want ErrShutdown", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	got := fmt.Sprint(states)
+	if !strings.HasPrefix(got, "[READY") || !strings.Contains(got, "TRANSIENT_FAILURE") || !strings.HasSuffix(got, "READY SHUTDOWN]") {
+		t.Fatalf("state changes = %s", got)
+	}
+}
+
+// 连接不可用时按WaitForReady等待或立即失败
+func TestReconnectingClientWaitForReady(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := "tcp@" + l.Addr().String()
+	_ = l.Close()
+	tests := []struct {
+		name         string
+		waitForReady bool
+		is           error
+	}{
+		{"fail fast", false, client.ErrDisconnected},
+		{"wait until ctx ends", true, GeeRPC.ErrTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := client.NewReconnectingClient(addr, &client.ReconnectPolicy{
+				BaseBackoff:  10 * time.Millisecond,
+				MaxBackoff:   10 * time.Millisecond,
+				WaitForReady: tt.waitForReady,
+			})
+			defer rc.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			var reply int
+			if err := rc.Call(ctx, "Echo.Echo", 1, &reply); !errors.Is(err, tt.is) {
+				t.Fatalf("err = %v, want %v", err, tt.is)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// 客户端运行状态的快照
+type Stats struct {
+	State               ConnState     //连接状态，StateReady或StateShutdown
+	Pending             int           //未完成的请求数，包含进行中的流
+	OldestPending       time.Duration //最早的未完成请求已等待的时间
+	OldestPendingMethod string        //最早的未完成请求的方法名
+	BytesSent           uint64
+	BytesReceived       uint64
+	Completed           uint64 //成功完成的请求数
+	Failed              uint64 //失败、超时或被取消的请求数
+}
+
+type byteCounter struct {
+	sent     uint64
+	received uint64
+}
+
+// 统计读写字节数的连接
+type countingConn struct {
+	net.Conn
+	bytes *byteCounter
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	atomic.AddUint64(&c.bytes.received, uint64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	atomic.AddUint64(&c.bytes.sent, uint64(n))
+	return n, err
+}
+
+// 记录调用结果并通知调用方
+func (client *Client) finish(call *Call) {
+	if call.Error != nil {
+		atomic.AddUint64(&client.failed, 1)
+	} else {
+		atomic.AddUint64(&client.completed, 1)
+	}
+	call.done()
+}
+
+// 客户端运行状态，用于排查卡住的调用
+func (client *Client) Stats() Stats {
+	client.mu.Lock()
+	s := Stats{State: StateReady, Pending: len(client.pending)}
+	if client.closing || client.shutdown {
+		s.State = StateShutdown
+	}
+	now := time.Now()
+	for _, call := range client.pending {
+		if age := now.Sub(call.start); age > s.OldestPending {
+			s.OldestPending, s.OldestPendingMethod = age, call.ServiceMethod
+		}
+	}
+	client.mu.Unlock()
+	s.BytesSent = atomic.LoadUint64(&client.bytes.sent)
+	s.BytesReceived = atomic.LoadUint64(&client.bytes.received)
+	s.Completed = atomic.LoadUint64(&client.completed)
+	s.Failed = atomic.LoadUint64(&client.failed)
+	return s
+}
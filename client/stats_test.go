@@ -0,0 +1,43 @@
+package client_test
+
+import (
+	"codec/client"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	s := newRestartableServer(t)
+	c, err := client.Dial("tcp", s.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := c.Stats(); st.State != client.StateReady || st.Pending != 0 || st.BytesSent == 0 {
+		t.Fatalf("initial stats = %+v, want ready with the handshake sent", st)
+	}
+	ctx := context.Background()
+	var reply int
+	_ = c.Call(ctx, "Echo.Echo", 1, &reply)
+	_ = c.Call(ctx, "Echo.Missing", 1, &reply)
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	_ = c.Call(timeout, "Echo.Sleep", time.Second, &reply)
+	cancel()
+
+	//卡住的调用可以从最早的未完成请求看出
+	slow := c.Go("Echo.Sleep", 200*time.Millisecond, &reply, nil)
+	time.Sleep(50 * time.Millisecond)
+	st := c.Stats()
+	if st.Pending != 1 || st.OldestPendingMethod != "Echo.Sleep" || st.OldestPending < 50*time.Millisecond {
+		t.Fatalf("stats with a slow call = %+v", st)
+	}
+	<-slow.Done
+	st = c.Stats()
+	if st.Pending != 0 || st.Completed != 2 || st.Failed != 2 || st.BytesReceived == 0 {
+		t.Fatalf("stats = %+v, want 2 completed, 2 failed", st)
+	}
+	_ = c.Close()
+	if st := c.Stats(); st.State != client.StateShutdown {
+		t.Fatalf("state after Close = %s", st.State)
+	}
+}
@@ -0,0 +1,240 @@
+package client
+
+import (
+	GeeRPC "codec"
+	"codec/codec"
+	"codec/flow"
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+)
+
+var ErrStreamClosed = errors.New("rpc客户端: stream已关闭发送")
+
+// 流式调用，覆盖服务端流、客户端流与双向流
+//
+//	st := client.Stream(ctx, "Log.Tail", args, &line)
+//	for st.Next() {
+//		// 使用 line
+//	}
+//	err := st.Err()
+type Stream struct {
+	client *Client
+	call   *Call
+	ctx    context.Context
+	reply  reflect.Value //每条结果的接收指针
+	typ    reflect.Type  //单条结果类型，客户端流为nil
+	window *flow.Window  //发送额度
+	ack    *flow.Ack     //接收消费计数
+
+	mu         sync.Mutex
+	queue      []reflect.Value //已收到未读取的结果
+	notify     chan struct{}   //有新结果到达
+	done       bool            //最终响应已到达或已关闭
+	sendClosed bool            //已半关闭
+	err        error
+}
+
+// 发起服务端流式调用，reply为每条结果的接收指针
+func (client *Client) Stream(ctx context.Context, serviceMethod string, args, reply interface{}) *Stream {
+	return client.openStream(ctx, serviceMethod, args, reply, nil)
+}
+
+// 发起客户端流式调用，通过Send发送数据，CloseAndRecv结束并把唯一的响应写入reply
+func (client *Client) ClientStream(ctx context.Context, serviceMethod string, reply interface{}) *Stream {
+	return client.openStream(ctx, serviceMethod, struct{}{}, nil, reply)
+}
+
+// 发起双向流式调用，Send与Next可以在不同协程中并发使用
+func (client *Client) BidiStream(ctx context.Context, serviceMethod string, args, reply interface{}) *Stream {
+	return client.openStream(ctx, serviceMethod, args, reply, nil)
+}
+
+func (client *Client) openStream(ctx context.Context, serviceMethod string, args, reply, final interface{}) *Stream {
+	window := client.opt.StreamWindow
+	if window <= 0 {
+		window = GeeRPC.DefaultOption.StreamWindow
+	}
+	st := &Stream{
+		client: client,
+		ctx:    ctx,
+		notify: make(chan struct{}, 1),
+		window: flow.NewWindow(window),
+		ack:    flow.NewAck(window),
+	}
+	if reply != nil {
+		rv := reflect.ValueOf(reply)
+		if rv.Kind() != reflect.Ptr || rv.IsNil() {
+			st.done = true
+			st.err = errors.New("rpc客户端: stream reply 必须是非空指针")
+			return st
+		}
+		st.reply, st.typ = rv, rv.Type().Elem()
+	}
+	st.call = &Call{
+		ServiceMethod: serviceMethod,
+		Args:          args,
+		Reply:         final,
+		Done:          make(chan *Call, 1),
+		Metadata:      GeeRPC.MetadataFromContext(ctx),
+		stream:        st,
+	}
+	client.send(st.call)
+	return st
+}
+
+// 读取一条流数据，由接收协程调用
+func (st *Stream) receive(cc codec.Codec) error {
+	if st.typ == nil {
+		return cc.ReadBody(nil)
+	}
+	v := reflect.New(st.typ)
+	if err := cc.ReadBody(v.Interface()); err != nil {
+		return err
+	}
+	st.mu.Lock()
+	st.queue = append(st.queue, v.Elem())
+	st.mu.Unlock()
+	select {
+	case st.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// 取出下一条结果写入reply，流结束或出错时返回false
+func (st *Stream) Next() bool {
+	for {
+		st.mu.Lock()
+		if len(st.queue) > 0 {
+			v := st.queue[0]
+			st.queue = st.queue[1:]
+			st.mu.Unlock()
+			st.reply.Elem().Set(v)
+			if n := st.ack.Consume(); n > 0 {
+				_ = st.writeFrame(codec.FrameWindow, n)
+			}
+			return true
+		}
+		done := st.done
+		st.mu.Unlock()
+		if done {
+			return false
+		}
+		if !st.wait() {
+			return false
+		}
+	}
+}
+
+// 等待新结果或最终响应
+func (st *Stream) wait() bool {
+	select {
+	case <-st.notify:
+	case call := <-st.call.Done:
+		//最终响应之前的数据帧都已入队
+		st.finish(call.Error)
+	case <-st.ctx.Done():
+		st.cancel(ctxError("客户端流式调用超时", st.ctx.Err()))
+		return false
+	}
+	return true
+}
+
+// 发送一条消息，额度耗尽时等待服务端消费
+func (st *Stream) Send(msg interface{}) error {
+	st.mu.Lock()
+	if st.done || st.sendClosed {
+		err := st.err
+		st.mu.Unlock()
+		if err == nil {
+			err = ErrStreamClosed
+		}
+		return err
+	}
+	st.mu.Unlock()
+	if err := st.window.Acquire(st.ctx); err != nil {
+		//流已结束时返回结束的原因，如连接断开
+		if e := st.Err(); e != nil {
+			return e
+		}
+		return err
+	}
+	return st.writeFrame(codec.FrameStream, msg)
+}
+
+// 半关闭，通知服务端不再发送
+func (st *Stream) CloseSend() error {
+	st.mu.Lock()
+	if st.done || st.sendClosed {
+		st.mu.Unlock()
+		return nil
+	}
+	st.sendClosed = true
+	st.mu.Unlock()
+	return st.writeFrame(codec.FrameHalfClose, struct{}{})
+}
+
+// 结束客户端流并等待服务端的最终响应
+func (st *Stream) CloseAndRecv() error {
+	if err := st.CloseSend(); err != nil {
+		st.cancel(err)
+		return err
+	}
+	for {
+		st.mu.Lock()
+		done := st.done
+		st.mu.Unlock()
+		if done || !st.wait() {
+			return st.Err()
+		}
+	}
+}
+
+// 流的最终错误，正常结束为nil
+func (st *Stream) Err() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.err
+}
+
+// 提前结束流并通知服务端
+func (st *Stream) Close() error {
+	st.cancel(nil)
+	return nil
+}
+
+func (st *Stream) writeFrame(frame codec.FrameType, body interface{}) error {
+	return st.client.sendFrame(&codec.Header{
+		ServiceMethod: st.call.ServiceMethod,
+		Seq:           st.call.Seq,
+		Frame:         frame,
+	}, body)
+}
+
+func (st *Stream) finish(err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if !st.done {
+		st.done = true
+		st.err = err
+	}
+	st.window.Close()
+}
+
+func (st *Stream) cancel(err error) {
+	st.mu.Lock()
+	if st.done {
+		st.mu.Unlock()
+		return
+	}
+	st.done = true
+	st.err = err
+	st.queue = nil
+	st.mu.Unlock()
+	st.window.Close()
+	if st.client.removeCall(st.call.Seq) != nil {
+		_ = st.writeFrame(codec.FrameCancel, struct{}{})
+	}
+}
@@ -0,0 +1,36 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+)
+
+// 按编码类型单独编码一个值，用于批量请求中各调用的参数与结果
+func EncodeBody(t Type, v interface{}) ([]byte, error) {
+	switch t {
+	case GobType:
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case JsonType:
+		return json.Marshal(v)
+	default:
+		return nil, fmt.Errorf("rpc codec: unsupported codec type %s", t)
+	}
+}
+
+// 解码EncodeBody编码的值
+func DecodeBody(t Type, data []byte, v interface{}) error {
+	switch t {
+	case GobType:
+		return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+	case JsonType:
+		return json.Unmarshal(data, v)
+	default:
+		return fmt.Errorf("rpc codec: unsupported codec type %s", t)
+	}
+}
@@ -1,11 +1,41 @@
 package codec
 
-import "io"
+import (
+	"io"
+	"time"
+)
 
 type Header struct {
-	ServiceMethod string //请求方法
-	Seq           uint64 //请求序号
-	Error         string //错误信息
+	ServiceMethod string            //请求方法
+	Seq           uint64            //请求序号
+	Error         string            //错误信息
+	Frame         FrameType         //帧类型
+	Metadata      map[string]string //请求元数据
+	Code          uint32            //错误码
+	Details       map[string]string //错误详情
+	Retryable     bool              //错误可重试
+	RetryAfter    time.Duration     //限流时建议的重试间隔
+	NoReply       bool              //单向请求，服务端不发送响应
+}
+
+// 帧类型，同一Seq上可以有多个帧
+type FrameType uint8
+
+const (
+	FrameCall          FrameType = iota //普通请求或最终响应
+	FrameStream                         //流数据
+	FrameCancel                         //客户端取消流
+	FrameHalfClose                      //客户端发送完毕
+	FrameWindow                         //归还流的发送额度，请求体为额度数
+	FrameBatch                          //批量请求或其合并响应，请求体为[]BatchItem
+	FrameCallback                       //服务端发往客户端的回调请求，Seq由服务端分配
+	FrameCallbackReply                  //客户端对回调请求的响应
+)
+
+// 批量请求中的单个调用，Header携带方法名与错误，参数与结果单独编码在Body中
+type BatchItem struct {
+	Header Header
+	Body   []byte
 }
 
 type Codec interface {
@@ -15,6 +45,12 @@ type Codec interface {
 	Write(*Header, interface{}) error
 }
 
+// 支持批量写入的Codec，多次WriteBuffered后调用一次Flush写出
+type BatchWriter interface {
+	WriteBuffered(*Header, interface{}) error
+	Flush() error
+}
+
 type NewCodeFunc func(io.ReadWriteCloser) Codec
 
 type Type string
@@ -15,6 +15,7 @@ type GobCodec struct {
 }
 
 var _Codec = (*GobCodec)(nil) //检查GobCodec实现了Codec接口
+var _ BatchWriter = (*GobCodec)(nil)
 
 func NewGobCodec(conn io.ReadWriteCloser) Codec {
 	buf := bufio.NewWriter(conn)
@@ -41,7 +42,27 @@ func (c *GobCodec) Write(h *Header, body interface{}) (err error) {
 			_ = c.Close()
 		}
 	}()
+	return c.encode(h, body)
+}
+
+// 只写入缓冲区，缓冲区满时才写出，与Flush配合批量发送
+func (c *GobCodec) WriteBuffered(h *Header, body interface{}) error {
+	err := c.encode(h, body)
+	if err != nil {
+		_ = c.Close()
+	}
+	return err
+}
+
+func (c *GobCodec) Flush() error {
+	err := c.buf.Flush()
+	if err != nil {
+		_ = c.Close()
+	}
+	return err
+}
 
+func (c *GobCodec) encode(h *Header, body interface{}) error {
 	if err := c.enc.Encode(h); err != nil {
 		log.Println("rpc codec: gob error encoding header:", err)
 		return err
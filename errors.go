@@ -0,0 +1,105 @@
+package GeeRPC
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 错误码，随响应头传给客户端
+type Code uint32
+
+const (
+	CodeUnknown         Code = iota //未分类，处理函数返回的普通错误
+	CodeInvalidArgument             //请求不合法
+	CodeNotFound                    //服务或方法不存在
+	CodeTimeout                     //处理超时
+	CodeCanceled                    //调用被取消
+	CodeOverloaded                  //服务端过载
+	CodeRateLimited                 //触发限流
+	CodeUnavailable                 //连接不可用
+	CodeInternal                    //框架内部错误
+)
+
+var codeNames = map[Code]string{
+	CodeUnknown:         "unknown",
+	CodeInvalidArgument: "invalid argument",
+	CodeNotFound:        "not found",
+	CodeTimeout:         "timeout",
+	CodeCanceled:        "canceled",
+	CodeOverloaded:      "overloaded",
+	CodeRateLimited:     "rate limited",
+	CodeUnavailable:     "unavailable",
+	CodeInternal:        "internal",
+}
+
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("code(%d)", uint32(c))
+}
+
+// 带错误码的错误，处理函数返回*Error时错误码原样传给客户端
+type Error struct {
+	Code       Code
+	Message    string
+	Details    map[string]string //附加信息
+	Retryable  bool              //换实例或稍后重试可能成功
+	RetryAfter time.Duration     //建议的重试间隔
+	Cause      error             //底层错误，如连接断开时的io.EOF，只在本地使用，不随响应传输
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return "rpc error: " + e.Code.String()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// 配合errors.Is使用：目标是只有错误码的哨兵（如ErrUnavailable）时按错误码匹配
+// 带信息的错误（如client.ErrShutdown）只与自身匹配，同码的不同错误可以区分
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Message == "" && t.Code == e.Code
+}
+
+// 构造带错误码的错误
+func Errorf(code Code, format string, args ...interface{}) *Error {
+	return &Error{
+		Code:      code,
+		Message:   fmt.Sprintf(format, args...),
+		Retryable: code == CodeOverloaded || code == CodeRateLimited || code == CodeUnavailable,
+	}
+}
+
+// 用于errors.Is判断的错误
+var (
+	ErrInvalidArgument = &Error{Code: CodeInvalidArgument}
+	ErrNotFound        = &Error{Code: CodeNotFound}
+	ErrTimeout         = &Error{Code: CodeTimeout}
+	ErrCanceled        = &Error{Code: CodeCanceled}
+	ErrOverloaded      = &Error{Code: CodeOverloaded}
+	ErrRateLimited     = &Error{Code: CodeRateLimited}
+	ErrUnavailable     = &Error{Code: CodeUnavailable}
+	ErrInternal        = &Error{Code: CodeInternal}
+)
+
+// 取错误码，普通错误为CodeUnknown
+func CodeOf(err error) Code {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return CodeUnknown
+}
+
+// 判断错误是否可重试
+func IsRetryable(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Retryable
+}
@@ -0,0 +1,32 @@
+package GeeRPC_test
+
+import (
+	GeeRPC "codec"
+	"codec/client"
+	"errors"
+	"testing"
+)
+
+func TestErrorIs(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{"code sentinel", client.ErrShutdown, GeeRPC.ErrUnavailable, true},
+		{"coded error", GeeRPC.Errorf(GeeRPC.CodeNotFound, "no such method"), GeeRPC.ErrNotFound, true},
+		{"other code", GeeRPC.Errorf(GeeRPC.CodeNotFound, "no such method"), GeeRPC.ErrTimeout, false},
+		{"same sentinel", client.ErrShutdown, client.ErrShutdown, true},
+		{"disconnected is not shutdown", client.ErrDisconnected, client.ErrShutdown, false},
+		{"peer closed is not shutdown", GeeRPC.ErrPeerClosed, client.ErrShutdown, false},
+		{"same code and message", GeeRPC.Errorf(GeeRPC.CodeUnavailable, "connection is shut down"), client.ErrShutdown, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, tt.target); got != tt.want {
+				t.Fatalf("errors.Is(%v, %v) = %v, want %v", tt.err, tt.target, got, tt.want)
+			}
+		})
+	}
+}
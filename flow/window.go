@@ -0,0 +1,117 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrClosed = errors.New("flow: window closed")
+
+// 基于额度的发送窗口，额度耗尽时发送方阻塞
+type Window struct {
+	mu     sync.Mutex
+	credit int
+	closed bool
+	notify chan struct{} //有额度归还
+}
+
+func NewWindow(size int) *Window {
+	return &Window{
+		credit: size,
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// 获取一个额度
+func (w *Window) Acquire(ctx context.Context) error {
+	for {
+		w.mu.Lock()
+		if w.closed {
+			w.mu.Unlock()
+			return ErrClosed
+		}
+		if w.credit > 0 {
+			w.credit--
+			//还有剩余额度时唤醒其他等待者
+			if w.credit > 0 {
+				w.wake()
+			}
+			w.mu.Unlock()
+			return nil
+		}
+		w.mu.Unlock()
+		select {
+		case <-w.notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// 有额度时获取一个额度，不阻塞
+func (w *Window) TryAcquire() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed || w.credit == 0 {
+		return false
+	}
+	w.credit--
+	return true
+}
+
+// 归还额度
+func (w *Window) Release(n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.credit += n
+	w.wake()
+}
+
+// 关闭窗口，唤醒所有等待者
+func (w *Window) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		w.closed = true
+		close(w.notify)
+	}
+}
+
+func (w *Window) wake() {
+	if w.closed {
+		return
+	}
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// 接收方消费计数，消费达到半个窗口后归还额度
+type Ack struct {
+	mu        sync.Mutex
+	threshold int
+	consumed  int
+}
+
+func NewAck(size int) *Ack {
+	threshold := size / 2
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Ack{threshold: threshold}
+}
+
+// 记录一次消费，返回需要归还给发送方的额度，0表示暂不归还
+func (a *Ack) Consume() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.consumed++
+	if a.consumed < a.threshold {
+		return 0
+	}
+	n := a.consumed
+	a.consumed = 0
+	return n
+}
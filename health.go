@@ -0,0 +1,62 @@
+package GeeRPC
+
+// 服务的健康状态
+type ServingStatus int
+
+const (
+	StatusUnknown ServingStatus = iota
+	Serving                     //正常提供服务
+	NotServing                  //暂停服务，如依赖的数据库不可用
+)
+
+func (s ServingStatus) String() string {
+	switch s {
+	case Serving:
+		return "SERVING"
+	case NotServing:
+		return "NOT_SERVING"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// 健康检查服务名，每个Server都会注册
+const HealthServiceMethod = "Health.Check"
+
+// 健康检查请求，Service为空表示整个服务端
+type HealthCheckRequest struct {
+	Service string
+}
+
+type HealthCheckResponse struct {
+	Status ServingStatus
+}
+
+// 内置的健康检查服务
+type Health struct {
+	server *Server
+}
+
+// 查询服务的健康状态，未设置过状态的已注册服务视为Serving，未注册的服务返回ErrNotFound
+func (h *Health) Check(req HealthCheckRequest, resp *HealthCheckResponse) error {
+	if v, ok := h.server.health.Load(req.Service); ok {
+		resp.Status = v.(ServingStatus)
+		return nil
+	}
+	if req.Service != "" {
+		if _, ok := h.server.serviceMap.Load(req.Service); !ok {
+			return Errorf(CodeNotFound, "health: unknown service %s", req.Service)
+		}
+	}
+	resp.Status = Serving
+	return nil
+}
+
+// 设置服务的健康状态，service为空表示整个服务端
+func (server *Server) SetServingStatus(service string, status ServingStatus) {
+	server.health.Store(service, status)
+}
+
+func SetServingStatus(service string, status ServingStatus) {
+	DefaultServer.SetServingStatus(service, status)
+}
@@ -0,0 +1,101 @@
+package GeeRPC_test
+
+import (
+	GeeRPC "codec"
+	"codec/client"
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 记录同时执行的请求数
+type Gate struct {
+	release chan struct{}
+	running int64
+	peak    int64
+}
+
+func (g *Gate) Wait(n int, reply *int) error {
+	cur := atomic.AddInt64(&g.running, 1)
+	defer atomic.AddInt64(&g.running, -1)
+	for {
+		peak := atomic.LoadInt64(&g.peak)
+		if cur <= peak || atomic.CompareAndSwapInt64(&g.peak, peak, cur) {
+			break
+		}
+	}
+	<-g.release
+	*reply = n
+	return nil
+}
+
+// 客户端与服务端协商的窗口限制同时执行的请求数，超出的请求等待而不是失败
+func TestInflightWindow(t *testing.T) {
+	tests := []struct {
+		name   string
+		server int //服务端MaxConnInflight
+		client int //客户端MaxInflight
+		want   int64
+	}{
+		{"client window", 0, 2, 2},
+		{"server window", 3, 0, 3},
+		{"smaller wins", 2, 4, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &Gate{release: make(chan struct{})}
+			addr := startServer(t, func(s *GeeRPC.Server) { s.MaxConnInflight = tt.server }, g)
+			c := dial(t, addr, &GeeRPC.Option{MaxInflight: tt.client})
+			const n = 6
+			var wg sync.WaitGroup
+			errs := make(chan error, n)
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+					var reply int
+					errs <- c.Call(ctx, "Gate.Wait", i, &reply)
+				}(i)
+			}
+			time.Sleep(50 * time.Millisecond)
+			close(g.release)
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if peak := atomic.LoadInt64(&g.peak); peak != tt.want {
+				t.Fatalf("peak concurrency = %d, want %d", peak, tt.want)
+			}
+		})
+	}
+}
+
+// 不遵守窗口的客户端：超出额度的请求排队，队列满后返回ErrOverloaded
+func TestInflightQueueOverflow(t *testing.T) {
+	g := &Gate{release: make(chan struct{})}
+	addr := startServer(t, func(s *GeeRPC.Server) { s.MaxConnInflight = 1 }, g)
+	c := dial(t, addr)
+	done := make(chan *client.Call, 3)
+	running := c.Go("Gate.Wait", 1, new(int), done)
+	time.Sleep(20 * time.Millisecond)
+	queued := c.Go("Gate.Wait", 2, new(int), done)
+	time.Sleep(20 * time.Millisecond)
+	var reply int
+	if err := c.Call(context.Background(), "Gate.Wait", 3, &reply); GeeRPC.CodeOf(err) != GeeRPC.CodeOverloaded || !GeeRPC.IsRetryable(err) {
+		t.Fatalf("err = %v, want retryable overloaded", err)
+	}
+	close(g.release)
+	for _, call := range []*client.Call{running, queued} {
+		<-call.Done
+		if call.Error != nil {
+			t.Fatalf("%d: %v", call.Args, call.Error)
+		}
+	}
+}
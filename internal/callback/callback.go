@@ -0,0 +1,17 @@
+// 客户端处理服务端回调所需的内部接口，帧格式不属于公开API
+package callback
+
+import (
+	"codec/codec"
+	"context"
+)
+
+// 回调服务，由GeeRPC包实现
+type Handler interface {
+	Register(rcvr interface{}) error
+	// 处理一个回调请求帧，readBody在调用方的读协程中读取参数，方法在新协程中执行，结束后通过reply写回响应
+	Dispatch(ctx context.Context, h *codec.Header, readBody func(interface{}) error, reply func(*codec.Header, interface{})) error
+}
+
+// 创建回调服务，GeeRPC包初始化时设置
+var NewHandler func() Handler
@@ -0,0 +1,100 @@
+package GeeRPC
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// 并发限制，超过上限的请求进入有限队列，队列满时立即拒绝
+type Limit struct {
+	MaxConcurrent int //同时处理的请求上限，0表示不限制
+	MaxQueue      int //等待队列长度
+}
+
+type limiter struct {
+	scope    string
+	slots    chan struct{}
+	maxQueue int32
+	waiting  int32
+}
+
+func newLimiter(scope string, l Limit) *limiter {
+	if l.MaxConcurrent <= 0 {
+		return nil
+	}
+	return &limiter{
+		scope:    scope,
+		slots:    make(chan struct{}, l.MaxConcurrent),
+		maxQueue: int32(l.MaxQueue),
+	}
+}
+
+// 获取处理额度，队列已满时返回可重试的CodeOverloaded错误
+func (l *limiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if atomic.AddInt32(&l.waiting, 1) > l.maxQueue {
+		atomic.AddInt32(&l.waiting, -1)
+		return Errorf(CodeOverloaded, "rpc server: overloaded: %s", l.scope)
+	}
+	defer atomic.AddInt32(&l.waiting, -1)
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *limiter) release() {
+	if l != nil {
+		<-l.slots
+	}
+}
+
+// 设置整个服务端的并发限制
+func (server *Server) SetLimit(l Limit) {
+	server.limit.Store(newLimiter("server", l))
+}
+
+// 设置单个连接的并发限制，对之后建立的连接生效
+func (server *Server) SetConnLimit(l Limit) {
+	server.connLimit.Store(&l)
+}
+
+// 设置单个方法的并发限制，serviceMethod形如"Service.Method"
+func (server *Server) SetMethodLimit(serviceMethod string, l Limit) {
+	server.methodLimits.Store(serviceMethod, newLimiter("method "+serviceMethod, l))
+}
+
+// 检查限流后依次获取方法、连接、服务端的额度，返回的函数用于归还
+func (server *Server) admit(ctx context.Context, sc *serverConn, req *request) (func(), error) {
+	//限流不排队，先于并发限制检查
+	if err := server.allow(sc, req.h); err != nil {
+		return nil, err
+	}
+	var limiters []*limiter
+	if l, ok := server.methodLimits.Load(req.h.ServiceMethod); ok {
+		limiters = append(limiters, l.(*limiter))
+	}
+	limiters = append(limiters, sc.limiter, server.limit.Load())
+	for i, l := range limiters {
+		if err := l.acquire(ctx); err != nil {
+			for _, held := range limiters[:i] {
+				held.release()
+			}
+			return nil, err
+		}
+	}
+	return func() {
+		for _, l := range limiters {
+			l.release()
+		}
+	}, nil
+}
@@ -0,0 +1,74 @@
+package GeeRPC_test
+
+import (
+	GeeRPC "codec"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type Ticker struct{}
+
+// 持续推送直到客户端取消
+func (Ticker) Watch(n int, stream GeeRPC.ServerStream) error {
+	for i := 0; ; i++ {
+		if err := stream.Send(i); err != nil {
+			return err
+		}
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (Ticker) Echo(n int, reply *int) error {
+	*reply = n
+	return nil
+}
+
+// 长期存在的流不占用并发额度
+func TestStreamsDoNotHoldLimit(t *testing.T) {
+	addr := startServer(t, func(s *GeeRPC.Server) {
+		s.SetLimit(GeeRPC.Limit{MaxConcurrent: 1})
+		s.SetConnLimit(GeeRPC.Limit{MaxConcurrent: 1})
+		s.SetMethodLimit("Ticker.Watch", GeeRPC.Limit{MaxConcurrent: 1})
+	}, Ticker{})
+	c := dial(t, addr)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	streamCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	var tick int
+	for i := 0; i < 2; i++ {
+		if st := c.Stream(streamCtx, "Ticker.Watch", 0, &tick); !st.Next() {
+			t.Fatalf("stream %d: %v", i, st.Err())
+		}
+	}
+	var reply int
+	if err := c.Call(ctx, "Ticker.Echo", 42, &reply); err != nil || reply != 42 {
+		t.Fatalf("Echo with open streams = %d, %v", reply, err)
+	}
+}
+
+func TestRateLimitError(t *testing.T) {
+	addr := startServer(t, func(s *GeeRPC.Server) {
+		s.SetMethodRate("Ticker.Echo", GeeRPC.Rate{Limit: 1, Burst: 1})
+	}, Ticker{})
+	c := dial(t, addr)
+	var reply int
+	if err := c.Call(context.Background(), "Ticker.Echo", 1, &reply); err != nil {
+		t.Fatal(err)
+	}
+	err := c.Call(context.Background(), "Ticker.Echo", 1, &reply)
+	var e *GeeRPC.RateLimitError
+	if !errors.As(err, &e) || !errors.Is(err, GeeRPC.ErrRateLimited) {
+		t.Fatalf("err = %v, want rate limit error", err)
+	}
+	if e.RetryAfter <= 0 || e.Details["scope"] != "method Ticker.Echo" || !e.Retryable {
+		t.Fatalf("RetryAfter = %s, scope = %q, retryable = %v", e.RetryAfter, e.Details["scope"], e.Retryable)
+	}
+}
@@ -0,0 +1,19 @@
+package GeeRPC
+
+import "context"
+
+// 请求元数据，随请求头发送
+type Metadata map[string]string
+
+type metadataKey struct{}
+
+// 把元数据附加到ctx，客户端调用时随请求发送
+func WithMetadata(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+// 取出ctx中的元数据
+func MetadataFromContext(ctx context.Context) Metadata {
+	md, _ := ctx.Value(metadataKey{}).(Metadata)
+	return md
+}
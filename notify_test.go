@@ -0,0 +1,51 @@
+package GeeRPC_test
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type Recorder struct {
+	got chan int //Record收到的值
+}
+
+func (r *Recorder) Record(n int, reply *int) error {
+	r.got <- n
+	return nil
+}
+
+func TestNotify(t *testing.T) {
+	r := &Recorder{got: make(chan int, 1)}
+	addr := startServer(t, nil, &Arith{}, r)
+	c := dial(t, addr)
+	tests := []struct {
+		name   string
+		method string
+		arg    int
+	}{
+		{"executed", "Recorder.Record", 7},
+		{"unknown method is dropped", "Recorder.Missing", 8},
+	}
+	for _, tt := range tests {
+		if err := c.Notify(context.Background(), tt.method, tt.arg); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+	}
+	select {
+	case n := <-r.got:
+		if n != 7 {
+			t.Fatalf("Record got %d, want 7", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("notify was not executed")
+	}
+	//单向请求不登记pending，出错的单向请求不影响后续调用
+	if n := c.Pending(); n != 0 {
+		t.Fatalf("Pending = %d after Notify, want 0", n)
+	}
+	var reply int
+	if err := c.Call(context.Background(), "Arith.Add", [2]int{2, 3}, &reply); err != nil || reply != 5 {
+		t.Fatalf("Add after Notify = %d, %v", reply, err)
+	}
+}
@@ -0,0 +1,196 @@
+package GeeRPC
+
+import (
+	"codec/codec"
+	"codec/internal/callback"
+	"context"
+	"reflect"
+	"sync"
+)
+
+// 调用方连接的句柄，服务端通过它调用客户端注册的方法
+// 回调请求与普通请求复用同一连接，Seq由服务端单独分配
+type Peer struct {
+	sc      *serverConn
+	mu      sync.Mutex
+	seq     uint64
+	pending map[uint64]*peerCall
+	closed  bool
+	done    chan struct{}
+}
+
+type peerCall struct {
+	reply interface{}
+	err   error
+	done  chan struct{}
+}
+
+type peerKey struct{}
+
+func newPeer(sc *serverConn) *Peer {
+	return &Peer{sc: sc, seq: 1, pending: make(map[uint64]*peerCall), done: make(chan struct{})}
+}
+
+// 取出调用方连接，处理函数的第一个参数为context.Context时可用
+func PeerFromContext(ctx context.Context) (*Peer, bool) {
+	p, ok := ctx.Value(peerKey{}).(*Peer)
+	return p, ok
+}
+
+// 处理函数的ctx，携带调用方连接与请求元数据
+func (sc *serverConn) callContext(ctx context.Context, h *codec.Header) context.Context {
+	ctx = context.WithValue(ctx, peerKey{}, sc.peer)
+	if h.Metadata != nil {
+		ctx = WithMetadata(ctx, h.Metadata)
+	}
+	return ctx
+}
+
+// 客户端地址
+func (p *Peer) RemoteAddr() string {
+	return p.sc.remoteAddr
+}
+
+// 连接断开后关闭
+func (p *Peer) Done() <-chan struct{} {
+	return p.done
+}
+
+// 调用客户端通过Client.Register注册的方法，等待响应或ctx结束
+func (p *Peer) Call(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	call := &peerCall{reply: reply, done: make(chan struct{})}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrPeerClosed
+	}
+	seq := p.seq
+	p.seq++
+	p.pending[seq] = call
+	p.mu.Unlock()
+
+	h := &codec.Header{
+		ServiceMethod: serviceMethod,
+		Seq:           seq,
+		Frame:         codec.FrameCallback,
+		Metadata:      MetadataFromContext(ctx),
+	}
+	p.sc.sending.Lock()
+	err := p.sc.cc.Write(h, args)
+	p.sc.sending.Unlock()
+	if err != nil {
+		p.remove(seq)
+		return Errorf(CodeUnavailable, "rpc server: 发送回调请求失败 %s", err)
+	}
+	select {
+	case <-ctx.Done():
+		p.remove(seq)
+		if ctx.Err() == context.DeadlineExceeded {
+			return Errorf(CodeTimeout, "rpc server: 回调超时 %s", ctx.Err())
+		}
+		return Errorf(CodeCanceled, "rpc server: 回调已取消 %s", ctx.Err())
+	case <-call.done:
+		return call.err
+	}
+}
+
+var ErrPeerClosed = &Error{Code: CodeUnavailable, Message: "rpc server: peer connection closed", Retryable: true}
+
+func (p *Peer) remove(seq uint64) *peerCall {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	call := p.pending[seq]
+	delete(p.pending, seq)
+	return call
+}
+
+// 读取回调响应，在连接的读协程中调用
+func (p *Peer) receive(h *codec.Header) error {
+	call := p.remove(h.Seq)
+	if call == nil {
+		return p.sc.cc.ReadBody(nil)
+	}
+	defer close(call.done)
+	if h.Error != "" {
+		call.err = &Error{
+			Code:       Code(h.Code),
+			Message:    h.Error,
+			Details:    h.Details,
+			Retryable:  h.Retryable,
+			RetryAfter: h.RetryAfter,
+		}
+		return p.sc.cc.ReadBody(nil)
+	}
+	if err := p.sc.cc.ReadBody(call.reply); err != nil {
+		call.err = Errorf(CodeInternal, "rpc server: 读取回调响应失败 %s", err)
+		return err
+	}
+	return nil
+}
+
+// 连接断开，结束所有未完成的回调
+func (p *Peer) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	for seq, call := range p.pending {
+		call.err = ErrPeerClosed
+		close(call.done)
+		delete(p.pending, seq)
+	}
+	close(p.done)
+}
+
+// 客户端的回调服务，只通过内部包暴露给client
+type callbackHandler struct {
+	*Server
+}
+
+func init() {
+	callback.NewHandler = func() callback.Handler { return callbackHandler{NewServer()} }
+}
+
+func (h callbackHandler) Dispatch(ctx context.Context, hd *codec.Header, readBody func(interface{}) error, reply func(*codec.Header, interface{})) error {
+	return h.dispatch(ctx, hd, readBody, reply)
+}
+
+// 执行对端发起的回调请求，供客户端处理服务端通过Peer发起的调用
+// readBody读取请求参数，在调用方的读协程中执行；方法在新协程中执行，结束后通过reply写回响应
+func (server *Server) dispatch(ctx context.Context, h *codec.Header, readBody func(interface{}) error, reply func(*codec.Header, interface{})) error {
+	rh := &codec.Header{ServiceMethod: h.ServiceMethod, Seq: h.Seq, Frame: codec.FrameCallbackReply}
+	svc, mtype, err := server.findService(h.ServiceMethod)
+	if err == nil && mtype.kind != unaryMethod {
+		err = Errorf(CodeInvalidArgument, "rpc: 流式方法%s不能作为回调", h.ServiceMethod)
+	}
+	if err != nil {
+		if err := readBody(nil); err != nil {
+			return err
+		}
+		setError(rh, err)
+		go reply(rh, invalidRequest)
+		return nil
+	}
+	argv, replyv := mtype.NewArgv(), mtype.newReplyv()
+	argvi := argv.Interface()
+	if argv.Type().Kind() != reflect.Ptr {
+		argvi = argv.Addr().Interface()
+	}
+	if err := readBody(argvi); err != nil {
+		return err
+	}
+	if h.Metadata != nil {
+		ctx = WithMetadata(ctx, h.Metadata)
+	}
+	go func() {
+		if err := svc.call(ctx, mtype, argv, replyv); err != nil {
+			setError(rh, err)
+			reply(rh, invalidRequest)
+			return
+		}
+		reply(rh, replyv.Interface())
+	}()
+	return nil
+}
@@ -0,0 +1,109 @@
+package GeeRPC_test
+
+import (
+	GeeRPC "codec"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type Asker struct {
+	release chan struct{}
+}
+
+func (a *Asker) Slow(n int, reply *int) error {
+	<-a.release
+	*reply = n
+	return nil
+}
+
+// 通过调用方连接回调客户端的Cb.Double
+func (a *Asker) Ask(ctx context.Context, n int, reply *int) error {
+	peer, ok := GeeRPC.PeerFromContext(ctx)
+	if !ok {
+		return errors.New("no peer in context")
+	}
+	var doubled int
+	if err := peer.Call(ctx, "Cb.Double", n, &doubled); err != nil {
+		return err
+	}
+	*reply = doubled + 1
+	return nil
+}
+
+func (a *Asker) Log(n int, reply *int) error { return nil }
+
+type Cb struct {
+	gate chan struct{} //非nil时等待关闭后再返回
+}
+
+func (cb Cb) Double(n int, reply *int) error {
+	if cb.gate != nil {
+		<-cb.gate
+	}
+	*reply = n * 2
+	return nil
+}
+
+func TestPeerCall(t *testing.T) {
+	addr := startServer(t, nil, &Asker{})
+	c := dial(t, addr)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var reply int
+	err := c.Call(ctx, "Asker.Ask", 3, &reply)
+	if !errors.Is(err, GeeRPC.ErrNotFound) {
+		t.Fatalf("callback without Register: err = %v, want ErrNotFound", err)
+	}
+	if err := c.Register(Cb{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Call(ctx, "Asker.Ask", 3, &reply); err != nil || reply != 7 {
+		t.Fatalf("Ask(3) = %d, %v; want 7", reply, err)
+	}
+}
+
+// 处理额度耗尽时，读协程仍需读取回调响应
+func TestPeerCallWithFullInflight(t *testing.T) {
+	a := &Asker{release: make(chan struct{})}
+	addr := startServer(t, func(s *GeeRPC.Server) { s.MaxConnInflight = 1 }, a)
+	c := dial(t, addr)
+	gate := make(chan struct{})
+	if err := c.Register(Cb{gate: gate}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	slow := make(chan error, 1)
+	go func() {
+		var reply int
+		slow <- c.Call(ctx, "Asker.Slow", 1, &reply)
+	}()
+	ask := make(chan error, 1)
+	var reply int
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		ask <- c.Call(ctx, "Asker.Ask", 3, &reply)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(a.release)
+	if err := <-slow; err != nil {
+		t.Fatal(err)
+	}
+	//Ask占满额度并等待回调，回调响应在Notify之后才写出
+	if err := c.Notify(ctx, "Asker.Log", 1); err != nil {
+		t.Fatal(err)
+	}
+	close(gate)
+	select {
+	case err := <-ask:
+		if err != nil || reply != 7 {
+			t.Fatalf("Ask(3) = %d, %v; want 7", reply, err)
+		}
+	case <-ctx.Done():
+		t.Fatal("Ask blocked: callback reply not read while inflight is full")
+	}
+}
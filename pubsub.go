@@ -0,0 +1,109 @@
+package GeeRPC
+
+import (
+	"sync"
+)
+
+// 发布订阅服务的方法名
+const (
+	PubSubSubscribe = "PubSub.Subscribe"
+	PubSubPublish   = "PubSub.Publish"
+)
+
+// 订阅者每个连接缓存的事件数
+const DefaultSubscriberBuffer = 64
+
+// 发布到主题的事件，Data由应用自行编码
+type Event struct {
+	Topic string
+	Data  []byte
+}
+
+type SubscribeRequest struct {
+	Topic string
+}
+
+// 按主题的发布订阅服务，通过server.Register(NewPubSub())启用
+// 订阅者消费过慢、缓存已满时丢弃新事件，不阻塞发布方
+type PubSub struct {
+	Buffer int //每个订阅者缓存的事件数，<=0时使用DefaultSubscriberBuffer
+
+	mu     sync.Mutex
+	topics map[string]map[chan Event]struct{}
+}
+
+func NewPubSub() *PubSub {
+	return &PubSub{topics: make(map[string]map[chan Event]struct{})}
+}
+
+// 订阅主题，事件通过服务端流推送，直到客户端取消或连接断开
+func (ps *PubSub) Subscribe(req SubscribeRequest, stream ServerStream) error {
+	if req.Topic == "" {
+		return Errorf(CodeInvalidArgument, "pubsub: empty topic")
+	}
+	ch := ps.add(req.Topic)
+	defer ps.remove(req.Topic, ch)
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ev := <-ch:
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// 把事件投递给本服务端上该主题的订阅者，delivered为成功投递的订阅者数
+func (ps *PubSub) Publish(ev Event, delivered *int) error {
+	if ev.Topic == "" {
+		return Errorf(CodeInvalidArgument, "pubsub: empty topic")
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	n := 0
+	for ch := range ps.topics[ev.Topic] {
+		select {
+		case ch <- ev:
+			n++
+		default:
+		}
+	}
+	*delivered = n
+	return nil
+}
+
+// 主题在本服务端上的订阅者数
+func (ps *PubSub) Subscribers(topic string) int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return len(ps.topics[topic])
+}
+
+func (ps *PubSub) add(topic string) chan Event {
+	buffer := ps.Buffer
+	if buffer <= 0 {
+		buffer = DefaultSubscriberBuffer
+	}
+	ch := make(chan Event, buffer)
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	subs := ps.topics[topic]
+	if subs == nil {
+		subs = make(map[chan Event]struct{})
+		ps.topics[topic] = subs
+	}
+	subs[ch] = struct{}{}
+	return ch
+}
+
+func (ps *PubSub) remove(topic string, ch chan Event) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	subs := ps.topics[topic]
+	delete(subs, ch)
+	if len(subs) == 0 {
+		delete(ps.topics, topic)
+	}
+}
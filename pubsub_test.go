@@ -0,0 +1,120 @@
+package GeeRPC_test
+
+import (
+	GeeRPC "codec"
+	"context"
+	"testing"
+	"time"
+)
+
+// 等待主题的订阅者数达到n
+func waitSubscribers(t *testing.T, ps *GeeRPC.PubSub, topic string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for ps.Subscribers(topic) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s has %d subscribers, want %d", topic, ps.Subscribers(topic), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPubSub(t *testing.T) {
+	ps := GeeRPC.NewPubSub()
+	addr := startServer(t, nil, ps)
+	c := dial(t, addr)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var orders, users GeeRPC.Event
+	ordersSt := c.Subscribe(ctx, "orders", &orders)
+	usersCtx, unsubscribe := context.WithCancel(ctx)
+	usersSt := c.Subscribe(usersCtx, "users", &users)
+	waitSubscribers(t, ps, "orders", 1)
+	waitSubscribers(t, ps, "users", 1)
+
+	tests := []struct {
+		topic     string
+		data      string
+		delivered int
+	}{
+		{"orders", "o1", 1},
+		{"users", "u1", 1},
+		{"orders", "o2", 1},
+		{"nobody", "x", 0},
+	}
+	for _, tt := range tests {
+		var delivered int
+		if err := c.Call(ctx, GeeRPC.PubSubPublish, GeeRPC.Event{Topic: tt.topic, Data: []byte(tt.data)}, &delivered); err != nil {
+			t.Fatal(err)
+		}
+		if delivered != tt.delivered {
+			t.Fatalf("publish %s: delivered = %d, want %d", tt.topic, delivered, tt.delivered)
+		}
+	}
+	//每个订阅者只收到自己主题的事件，顺序与发布顺序一致
+	for _, want := range []string{"o1", "o2"} {
+		if !ordersSt.Next() || orders.Topic != "orders" || string(orders.Data) != want {
+			t.Fatalf("orders event = %s %q, want %q (err %v)", orders.Topic, orders.Data, want, ordersSt.Err())
+		}
+	}
+	if !usersSt.Next() || string(users.Data) != "u1" {
+		t.Fatalf("users event = %q, want u1 (err %v)", users.Data, usersSt.Err())
+	}
+	//取消ctx即结束订阅
+	unsubscribe()
+	for usersSt.Next() {
+	}
+	waitSubscribers(t, ps, "users", 0)
+	if ps.Subscribers("orders") != 1 {
+		t.Fatal("unsubscribing users removed the orders subscriber")
+	}
+}
+
+func TestPubSubEmptyTopic(t *testing.T) {
+	addr := startServer(t, nil, GeeRPC.NewPubSub())
+	c := dial(t, addr)
+	var ev GeeRPC.Event
+	st := c.Subscribe(context.Background(), "", &ev)
+	if st.Next() || GeeRPC.CodeOf(st.Err()) != GeeRPC.CodeInvalidArgument {
+		t.Fatalf("subscribe to empty topic: %v", st.Err())
+	}
+	var delivered int
+	if err := c.Call(context.Background(), GeeRPC.PubSubPublish, GeeRPC.Event{}, &delivered); GeeRPC.CodeOf(err) != GeeRPC.CodeInvalidArgument {
+		t.Fatalf("publish to empty topic: %v", err)
+	}
+}
+
+// 订阅者不消费时丢弃新事件，发布方不被阻塞
+func TestPubSubSlowSubscriber(t *testing.T) {
+	ps := GeeRPC.NewPubSub()
+	ps.Buffer = 1
+	addr := startServer(t, nil, ps)
+	c := dial(t, addr, &GeeRPC.Option{StreamWindow: 4})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var ev GeeRPC.Event
+	c.Subscribe(ctx, "orders", &ev)
+	waitSubscribers(t, ps, "orders", 1)
+	done := make(chan int, 1)
+	go func() {
+		dropped := 0
+		for i := 0; i < 50; i++ {
+			var delivered int
+			_ = ps.Publish(GeeRPC.Event{Topic: "orders"}, &delivered)
+			if delivered == 0 {
+				dropped++
+			}
+			time.Sleep(time.Millisecond)
+		}
+		done <- dropped
+	}()
+	select {
+	case dropped := <-done:
+		if dropped == 0 {
+			t.Fatal("no event dropped for a subscriber that never reads")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("publish blocked on a slow subscriber")
+	}
+}
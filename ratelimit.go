@@ -0,0 +1,130 @@
+package GeeRPC
+
+import (
+	"codec/codec"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 令牌桶限流规则
+type Rate struct {
+	Limit     float64 //每秒产生的令牌数
+	Burst     int     //桶容量
+	PerClient bool    //按客户端分别限流
+	ClientKey string  //识别客户端的metadata键，为空或请求未携带时使用远端地址
+}
+
+// 单个令牌桶，按时间差惰性补充令牌
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) take(r Rate, now time.Time) (bool, time.Duration) {
+	b.tokens += now.Sub(b.last).Seconds() * r.Limit
+	if burst := float64(r.Burst); b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if r.Limit <= 0 {
+		return false, time.Second
+	}
+	return false, time.Duration((1 - b.tokens) / r.Limit * float64(time.Second))
+}
+
+// 按客户端分别限流时桶数量达到该值后清理已满的桶
+const maxIdleBuckets = 4096
+
+type rateLimiter struct {
+	scope   string
+	rate    Rate
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter(scope string, r Rate) *rateLimiter {
+	if r.Burst < 1 {
+		r.Burst = 1
+	}
+	return &rateLimiter{
+		scope:   scope,
+		rate:    r,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// 限流错误，即错误码为CodeRateLimited的*Error，RetryAfter为建议的重试间隔，Details["scope"]为触发的限流范围
+// 保留该类型名，errors.As(err, &rateLimitErr)与errors.Is(err, ErrRateLimited)的写法继续可用
+type RateLimitError = Error
+
+// 取一个令牌，失败时返回带重试间隔的CodeRateLimited错误
+func (l *rateLimiter) allow(remoteAddr string, md Metadata) error {
+	key := ""
+	if l.rate.PerClient {
+		key = remoteAddr
+		if v, ok := md[l.rate.ClientKey]; ok && l.rate.ClientKey != "" {
+			key = v
+		}
+	}
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.buckets[key]
+	if b == nil {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.sweep(now)
+		}
+		b = &tokenBucket{tokens: float64(l.rate.Burst), last: now}
+		l.buckets[key] = b
+	}
+	ok, retryAfter := b.take(l.rate, now)
+	if ok {
+		return nil
+	}
+	scope := l.scope
+	if key != "" {
+		scope = fmt.Sprintf("%s client %s", scope, key)
+	}
+	err := Errorf(CodeRateLimited, "rpc server: rate limited: %s", scope)
+	err.RetryAfter = retryAfter
+	err.Details = map[string]string{"scope": scope}
+	return err
+}
+
+// 清理已经补满的桶，这些客户端重新出现时等价于新桶
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate.Limit >= float64(l.rate.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// 设置服务级限流，作用于服务的所有方法
+func (server *Server) SetServiceRate(service string, r Rate) {
+	server.rateLimits.Store(service, newRateLimiter("service "+service, r))
+}
+
+// 设置方法级限流，serviceMethod形如"Service.Method"
+func (server *Server) SetMethodRate(serviceMethod string, r Rate) {
+	server.rateLimits.Store(serviceMethod, newRateLimiter("method "+serviceMethod, r))
+}
+
+// 依次检查服务级与方法级限流
+func (server *Server) allow(sc *serverConn, h *codec.Header) error {
+	dot := strings.LastIndex(h.ServiceMethod, ".")
+	for _, name := range []string{h.ServiceMethod[:dot], h.ServiceMethod} {
+		if l, ok := server.rateLimits.Load(name); ok {
+			if err := l.(*rateLimiter).allow(sc.remoteAddr, h.Metadata); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package registry
 import (
 	"log"
 	"net/http"
+	"net/url"
 	"sort"
 	"strings"
 	"sync"
@@ -16,8 +17,9 @@ type GeeRegistry struct {
 }
 
 type ServerItem struct {
-	Addr  string //服务地址
-	start time.Time
+	Addr     string //服务地址
+	Metadata string //实例metadata，url查询串格式，如"weight=3"
+	start    time.Time
 }
 
 const (
@@ -37,20 +39,32 @@ func New(timeout time.Duration) *GeeRegistry {
 var DefaultGeeRegistry = New(defaultTimeout)
 
 // 添加服务实例
-func (r *GeeRegistry) putServer(addr string) {
+func (r *GeeRegistry) putServer(addr, metadata string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	s := r.servers[addr]
 	if s == nil {
 		r.servers[addr] = &ServerItem{
-			Addr:  addr,
-			start: time.Now(),
+			Addr:     addr,
+			Metadata: metadata,
+			start:    time.Now(),
 		}
 	} else {
+		s.Metadata = metadata
 		s.start = time.Now()
 	}
 }
 
+// 返回实例的metadata
+func (r *GeeRegistry) serverMetadata(addr string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s := r.servers[addr]; s != nil {
+		return s.Metadata
+	}
+	return ""
+}
+
 // 返回可用服务
 func (r *GeeRegistry) aliveServers() []string {
 	r.mu.Lock()
@@ -71,15 +85,22 @@ func (r *GeeRegistry) aliveServers() []string {
 func (r *GeeRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	//"GET"拉取,"POST"注册
-	case "GRT":
-		w.Header().Set("X-Geerpc-Servers", strings.Join(r.aliveServers(), ","))
+	case "GET":
+		alive := r.aliveServers()
+		w.Header().Set("X-Geerpc-Servers", strings.Join(alive, ","))
+		//每个带metadata的实例一行，格式为"地址;metadata"
+		for _, addr := range alive {
+			if md := r.serverMetadata(addr); md != "" {
+				w.Header().Add("X-Geerpc-Metadata", addr+";"+md)
+			}
+		}
 	case "POST":
 		addr := req.Header.Get("X-Geerpc-Server")
 		if addr == "" {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		r.putServer(addr)
+		r.putServer(addr, req.Header.Get("X-Geerpc-Metadata"))
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
@@ -97,28 +118,47 @@ func HandleHTTP() {
 
 // 定时发送心跳
 func Heartbeat(registry, addr string, duration time.Duration) {
+	HeartbeatWithMetadata(registry, addr, nil, duration)
+}
+
+// 定时发送带metadata的心跳，如{"weight": "3"}
+func HeartbeatWithMetadata(registry, addr string, metadata map[string]string, duration time.Duration) {
 	if duration == 0 {
 		duration = defaultTimeout - time.Duration(1)*time.Minute
 	}
+	md := encodeMetadata(metadata)
 	var err error
-	err = sendHeartbeat(registry, addr)
+	err = sendHeartbeat(registry, addr, md)
 	go func() {
 		t := time.NewTicker(duration)
 		for err == nil {
 			<-t.C
-			err = sendHeartbeat(registry, addr)
+			err = sendHeartbeat(registry, addr, md)
 		}
 	}()
 }
 
+func encodeMetadata(metadata map[string]string) string {
+	values := url.Values{}
+	for k, v := range metadata {
+		values.Set(k, v)
+	}
+	return values.Encode()
+}
+
 // 发送心跳
-func sendHeartbeat(registry, addr string) error {
+func sendHeartbeat(registry, addr, metadata string) error {
 	httpClient := &http.Client{}
 	req, _ := http.NewRequest("POST", registry, nil)
 	req.Header.Set("X-Geerpc-Server", addr)
-	if _, err := httpClient.Do(req); err != nil {
+	if metadata != "" {
+		req.Header.Set("X-Geerpc-Metadata", metadata)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
 		log.Println("rpc心跳错误", err)
 		return err
 	}
+	_ = resp.Body.Close()
 	return nil
 }
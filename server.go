@@ -1,10 +1,11 @@
 package GeeRPC
 
 import (
+	"bufio"
 	"codec/codec"
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"go/ast"
 	"io"
 	"log"
@@ -29,21 +30,51 @@ type Option struct {
 	MagicNumber    int           //辨别rpc请求
 	CodecType      codec.Type    //编解码的类型
 	ConnectTimeout time.Duration //连接超时时间
-	HandleTimeout  time.Duration
+	HandleTimeout  time.Duration //服务端处理普通请求的超时时间，0表示默认的10秒
+	StreamWindow   int           //流的接收窗口，单位为消息数
+	MaxInflight    int           //单个连接未完成的普通请求上限，0表示由服务端决定
 }
 
+const (
+	defaultStreamWindow  = 64
+	defaultHandleTimeout = time.Second * 10
+)
+
 // 默认规则
 var DefaultOption = &Option{
 	MagicNumber:    MagicNumber,
 	CodecType:      codec.GobType,
 	ConnectTimeout: time.Second * 10,
+	StreamWindow:   defaultStreamWindow,
+}
+
+// 服务端处理超时，客户端未设置时取默认值
+func (opt *Option) handleTimeout() time.Duration {
+	if opt.HandleTimeout > 0 {
+		return opt.HandleTimeout
+	}
+	return defaultHandleTimeout
 }
 
+// 方法类型
+type methodKind int
+
+const (
+	unaryMethod        methodKind = iota //一问一答
+	serverStreamMethod                   //服务端流式返回
+	clientStreamMethod                   //客户端流式发送
+	bidiStreamMethod                     //双向流
+)
+
 // 方法的结构体
 type methodType struct {
 	method    reflect.Method
 	ArgType   reflect.Type
 	ReplyType reflect.Type
+	kind      methodKind
+	streamArg reflect.Type //接收流参数类型，如*ClientStream[T]
+	msgType   reflect.Type //接收流的消息类型
+	withCtx   bool         //第一个参数为context.Context
 	numCalls  uint64
 }
 
@@ -59,7 +90,7 @@ func (m *methodType) NewArgv() reflect.Value {
 	if m.ArgType.Kind() == reflect.Ptr {
 		Argv = reflect.New(m.ArgType.Elem())
 	} else {
-		Argv = reflect.New(m.ReplyType).Elem()
+		Argv = reflect.New(m.ArgType).Elem()
 	}
 	return Argv
 }
@@ -102,15 +133,70 @@ func (s *service) registerMethods() {
 	for i := 0; i < s.typ.NumMethod(); i++ {
 		method := s.typ.Method(i)
 		mType := method.Type
+		//第一个参数为context.Context的普通方法，可通过ctx取得元数据与调用方连接
+		if mType.NumIn() == 4 && mType.In(1) == typeOfContext && mType.NumOut() == 1 &&
+			mType.Out(0) == typeOfError {
+			argType, replyType := mType.In(2), mType.In(3)
+			if !isExportedOrBuiltinType(argType) || !isExportedOrBuiltinType(replyType) || replyType.Kind() != reflect.Ptr {
+				continue
+			}
+			s.method[method.Name] = &methodType{
+				method:    method,
+				ArgType:   argType,
+				ReplyType: replyType,
+				withCtx:   true,
+			}
+			continue
+		}
 		//方法的合理性判断
 		if mType.NumIn() != 3 || mType.NumOut() != 1 {
 			continue
 		}
-		if mType.Out(0) != reflect.TypeOf((*error)(nil)).Elem() {
+		if mType.Out(0) != typeOfError {
 			continue
 		}
 		argType, replyType := mType.In(1), mType.In(2)
-		if !isExportedOrBuiltinType(argType) || !isExportedOrBuiltinType(replyType) {
+		//第一个参数为*ClientStream[T]时是客户端流式方法
+		if kind, msgType, ok := parseRecvStream(argType); ok {
+			if kind != clientStreamMethod || !isExportedOrBuiltinType(replyType) {
+				continue
+			}
+			s.method[method.Name] = &methodType{
+				method:    method,
+				ReplyType: replyType,
+				kind:      clientStreamMethod,
+				streamArg: argType,
+				msgType:   msgType,
+			}
+			continue
+		}
+		if !isExportedOrBuiltinType(argType) {
+			continue
+		}
+		//第二个参数为ServerStream时是服务端流式方法
+		if replyType == typeOfServerStream {
+			s.method[method.Name] = &methodType{
+				method:  method,
+				ArgType: argType,
+				kind:    serverStreamMethod,
+			}
+			continue
+		}
+		//第二个参数为*BidiStream[T]时是双向流方法
+		if kind, msgType, ok := parseRecvStream(replyType); ok {
+			if kind != bidiStreamMethod {
+				continue
+			}
+			s.method[method.Name] = &methodType{
+				method:    method,
+				ArgType:   argType,
+				kind:      bidiStreamMethod,
+				streamArg: replyType,
+				msgType:   msgType,
+			}
+			continue
+		}
+		if !isExportedOrBuiltinType(replyType) {
 			continue
 		}
 		s.method[method.Name] = &methodType{
@@ -124,11 +210,20 @@ func isExportedOrBuiltinType(t reflect.Type) bool {
 	return ast.IsExported(t.Name()) || t.PkgPath() == ""
 }
 
-// 调用方法
-func (s *service) call(m *methodType, argv, replyv reflect.Value) error {
+var (
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// 调用方法，ctx只传给第一个参数为context.Context的方法
+func (s *service) call(ctx context.Context, m *methodType, argv, replyv reflect.Value) error {
 	atomic.AddUint64(&m.numCalls, 1)
 	f := m.method.Func
-	returnValues := f.Call([]reflect.Value{s.rcvr, argv, replyv})
+	in := []reflect.Value{s.rcvr, argv, replyv}
+	if m.withCtx {
+		in = []reflect.Value{s.rcvr, reflect.ValueOf(ctx), argv, replyv}
+	}
+	returnValues := f.Call(in)
 	if errInter := returnValues[0].Interface(); errInter != nil {
 		return errInter.(error)
 	}
@@ -136,11 +231,23 @@ func (s *service) call(m *methodType, argv, replyv reflect.Value) error {
 }
 
 type Server struct {
-	serviceMap sync.Map
+	serviceMap       sync.Map
+	MaxConnInflight  int //单个连接同时处理的普通请求上限，超出的请求排队，队列满时返回ErrOverloaded，0表示不限制
+	BatchParallelism int //批量请求中同时执行的调用数，0表示使用默认值
+
+	limit        atomic.Pointer[limiter] //服务端并发限制
+	connLimit    atomic.Pointer[Limit]   //连接并发限制
+	methodLimits sync.Map                //方法并发限制
+	rateLimits   sync.Map                //服务与方法的限流规则
+	health       sync.Map                //服务名 -> ServingStatus
 }
 
+const defaultMaxConnInflight = 1024
+
 func NewServer() *Server {
-	return &Server{}
+	server := &Server{MaxConnInflight: defaultMaxConnInflight}
+	_ = server.Register(&Health{server: server})
+	return server
 }
 
 // 注册服务
@@ -162,19 +269,19 @@ func Register(rcvr interface{}) error { return DefaultServer.Register(rcvr) }
 func (server *Server) findService(serviceMethod string) (svc *service, mtype *methodType, err error) {
 	dot := strings.LastIndex(serviceMethod, ".")
 	if dot < 0 {
-		err = errors.New("rpc服务名传递错误" + serviceMethod)
+		err = Errorf(CodeInvalidArgument, "rpc服务名传递错误%s", serviceMethod)
 		return
 	}
 	serviceName, methodName := serviceMethod[:dot], serviceMethod[dot+1:]
 	svci, ok := server.serviceMap.Load(serviceName)
 	if !ok {
-		err = errors.New("rpc服务查找失败" + serviceName)
+		err = Errorf(CodeNotFound, "rpc服务查找失败%s", serviceName)
 		return
 	}
 	svc = svci.(*service)
 	mtype = svc.method[methodName]
-	if mtype != nil {
-		err = errors.New("rpc服务找不到方法" + methodName)
+	if mtype == nil {
+		err = Errorf(CodeNotFound, "rpc服务找不到方法%s", methodName)
 	}
 	return
 }
@@ -196,7 +303,13 @@ func Accept(lis net.Listener) { DefaultServer.Accept(lis) }
 func (server *Server) ServeConn(conn io.ReadWriteCloser) {
 	defer func() { _ = conn.Close() }()
 	var opt Option
-	if err := json.NewDecoder(conn).Decode(&opt); err != nil {
+	//option以换行结尾，按行读取避免多读后续请求
+	r := bufio.NewReader(conn)
+	line, err := r.ReadBytes('\n')
+	if err == nil {
+		err = json.Unmarshal(line, &opt)
+	}
+	if err != nil {
 		log.Println("rpc server: options error: ", err)
 		return
 	}
@@ -209,26 +322,251 @@ func (server *Server) ServeConn(conn io.ReadWriteCloser) {
 		log.Printf("rpc server: invalid codec type %s", opt.CodecType)
 		return
 	}
-	server.serveCodec(f(conn))
+	server.serveCodec(f(&bufferedConn{Reader: r, WriteCloser: conn}), &opt, remoteHost(conn))
+}
+
+// 取客户端的主机地址，同一客户端的不同连接端口不同
+func remoteHost(conn io.ReadWriteCloser) string {
+	c, ok := conn.(net.Conn)
+	if !ok {
+		return ""
+	}
+	addr := c.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// 读取时优先消费已缓冲的数据
+type bufferedConn struct {
+	io.Reader
+	io.WriteCloser
 }
 
 var invalidRequest = struct{}{}
 
-func (server *Server) serveCodec(cc codec.Codec) {
-	sending := new(sync.Mutex) //保证完整响应
-	wg := new(sync.WaitGroup)  //等待所有响应结束
+// 单个连接的服务端状态
+type serverConn struct {
+	cc         codec.Codec
+	sending    sync.Mutex     //保证完整响应
+	wg         sync.WaitGroup //等待所有响应结束
+	mu         sync.Mutex
+	streams    map[uint64]*serverStream //进行中的流
+	window     int                      //每个流的窗口大小
+	inflight   int                      //同时处理的普通请求上限，0表示不限制
+	running    int                      //正在处理的普通请求数
+	queue      []func()                 //等待处理额度的请求，长度不超过inflight
+	limiter    *limiter                 //连接并发限制
+	remoteAddr string                   //客户端地址，用于按客户端限流
+	peer       *Peer                    //向客户端发起回调
+}
+
+func newServerConn(cc codec.Codec, opt *Option, maxInflight int) *serverConn {
+	window := opt.StreamWindow
+	if window <= 0 {
+		window = defaultStreamWindow
+	}
+	sc := &serverConn{
+		cc:      cc,
+		streams: make(map[uint64]*serverStream),
+		window:  window,
+	}
+	//取客户端与服务端上限中较小的一个
+	if opt.MaxInflight > 0 && (maxInflight <= 0 || opt.MaxInflight < maxInflight) {
+		maxInflight = opt.MaxInflight
+	}
+	if maxInflight > 0 {
+		sc.inflight = maxInflight
+	}
+	return sc
+}
+
+// 提交普通请求，额度耗尽时排队，队列已满时返回CodeOverloaded错误
+// 读协程不等待额度，回调响应、窗口与取消帧总能被及时读取
+func (sc *serverConn) dispatch(run func()) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	switch {
+	case sc.inflight <= 0 || sc.running < sc.inflight:
+		sc.running++
+		go run()
+	case len(sc.queue) < sc.inflight:
+		sc.queue = append(sc.queue, run)
+	default:
+		return Errorf(CodeOverloaded, "rpc server: overloaded: connection inflight")
+	}
+	return nil
+}
+
+// 归还处理额度，有排队的请求时直接交给它
+func (sc *serverConn) release() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if len(sc.queue) > 0 {
+		next := sc.queue[0]
+		sc.queue[0] = nil
+		sc.queue = sc.queue[1:]
+		go next()
+		return
+	}
+	sc.running--
+}
+
+// 连接断开时丢弃排队的请求
+func (sc *serverConn) dropQueued() {
+	sc.mu.Lock()
+	queued := len(sc.queue)
+	sc.queue = nil
+	sc.mu.Unlock()
+	for i := 0; i < queued; i++ {
+		sc.wg.Done()
+	}
+}
+
+// 在发送锁内写出一帧
+func (sc *serverConn) write(h *codec.Header, body interface{}) error {
+	sc.sending.Lock()
+	defer sc.sending.Unlock()
+	return sc.cc.Write(h, body)
+}
+
+// 查找流
+func (sc *serverConn) getStream(seq uint64) *serverStream {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.streams[seq]
+}
+
+// 登记流
+func (sc *serverConn) addStream(st *serverStream) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.streams[st.seq] = st
+}
+
+// 移除流
+func (sc *serverConn) removeStream(seq uint64) *serverStream {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	st := sc.streams[seq]
+	delete(sc.streams, seq)
+	return st
+}
+
+// 连接断开时取消所有流
+func (sc *serverConn) cancelStreams() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for seq, st := range sc.streams {
+		st.cancel()
+		delete(sc.streams, seq)
+	}
+}
+
+// 处理非请求帧
+func (sc *serverConn) handleFrame(h *codec.Header) error {
+	switch h.Frame {
+	case codec.FrameStream:
+		if st := sc.getStream(h.Seq); st != nil && st.msgType != nil {
+			return st.receive(sc.cc)
+		}
+	case codec.FrameWindow:
+		var n int
+		if err := sc.cc.ReadBody(&n); err != nil {
+			return err
+		}
+		if st := sc.getStream(h.Seq); st != nil {
+			st.window.Release(n)
+		}
+		return nil
+	case codec.FrameHalfClose:
+		if st := sc.getStream(h.Seq); st != nil {
+			st.closeRecv()
+		}
+	case codec.FrameCancel:
+		if st := sc.removeStream(h.Seq); st != nil {
+			st.cancel()
+		}
+	case codec.FrameCallbackReply:
+		return sc.peer.receive(h)
+	default:
+		log.Printf("rpc server: 未知的帧类型 %d", h.Frame)
+	}
+	return sc.cc.ReadBody(nil)
+}
+
+func (server *Server) serveCodec(cc codec.Codec, opt *Option, remoteAddr string) {
+	sc := newServerConn(cc, opt, server.MaxConnInflight)
+	sc.remoteAddr = remoteAddr
+	sc.peer = newPeer(sc)
+	if l := server.connLimit.Load(); l != nil {
+		sc.limiter = newLimiter("connection", *l)
+	}
 	for {
-		req, err := server.readRequest(cc)
+		h, err := server.readRequestHeader(cc)
 		if err != nil {
-			if req == nil {
+			break
+		}
+		if h.Frame == codec.FrameBatch {
+			var items []codec.BatchItem
+			if err = cc.ReadBody(&items); err != nil {
 				break
 			}
-			server.sendResponse(cc, req.h, invalidRequest, sending)
+			sc.wg.Add(1)
+			if err = sc.dispatch(func() { server.handleBatch(sc, h, items, opt) }); err != nil {
+				sc.wg.Done()
+				setError(h, err)
+				server.sendResponse(cc, h, invalidRequest, &sc.sending)
+			}
+			continue
+		}
+		if h.Frame != codec.FrameCall {
+			if err = sc.handleFrame(h); err != nil {
+				break
+			}
+			continue
+		}
+		req, err := server.readRequest(cc, h)
+		if err != nil && h.NoReply {
+			log.Println("rpc server: 单向请求出错:", err)
+			continue
+		}
+		if err != nil {
+			setError(req.h, err)
+			server.sendResponse(cc, req.h, invalidRequest, &sc.sending)
+			continue
+		}
+		if h.NoReply && req.mtype.kind != unaryMethod {
+			log.Printf("rpc server: 流式方法%s不支持单向请求", h.ServiceMethod)
+			continue
+		}
+		sc.wg.Add(1)
+		if req.mtype.kind != unaryMethod {
+			st := newServerStream(sc, req)
+			sc.addStream(st)
+			go server.handleStream(sc, req, st)
+			continue
+		}
+		//流由各自的窗口控制，只有普通请求占用连接额度
+		run := func() { server.handlerRequest(sc, req, opt.handleTimeout()) }
+		if h.NoReply {
+			run = func() { server.handleNotify(sc, req, opt.handleTimeout()) }
+		}
+		if err = sc.dispatch(run); err != nil {
+			sc.wg.Done()
+			if h.NoReply {
+				log.Printf("rpc server: 单向请求%s被丢弃: %s", h.ServiceMethod, err)
+				continue
+			}
+			setError(req.h, err)
+			server.sendResponse(cc, req.h, invalidRequest, &sc.sending)
 		}
-		wg.Add(1)
-		server.handlerRequest(cc, req, sending, wg, time.Second*10)
 	}
-	wg.Wait()
+	sc.dropQueued()
+	sc.cancelStreams()
+	sc.peer.close()
+	sc.wg.Wait()
 	_ = cc.Close()
 }
 
@@ -251,19 +589,25 @@ func (server *Server) readRequestHeader(cc codec.Codec) (*codec.Header, error) {
 	return &h, nil
 }
 
-// 读取请求
-func (server *Server) readRequest(cc codec.Codec) (*request, error) {
-	h, err := server.readRequestHeader(cc)
-	if err != nil {
-		return nil, err
-	}
+// 读取请求体
+func (server *Server) readRequest(cc codec.Codec, h *codec.Header) (*request, error) {
+	var err error
 	req := &request{h: h}
 	req.svc, req.mtype, err = server.findService(h.ServiceMethod)
 	if err != nil {
+		//丢弃请求体，保证后续请求能正常解析
+		_ = cc.ReadBody(nil)
 		return req, err
 	}
+	if req.mtype.kind == clientStreamMethod {
+		//客户端流的数据全部通过流发送
+		req.replyv = req.mtype.newReplyv()
+		return req, cc.ReadBody(nil)
+	}
 	req.argv = req.mtype.NewArgv()
-	req.replyv = req.mtype.newReplyv()
+	if req.mtype.kind == unaryMethod {
+		req.replyv = req.mtype.newReplyv()
+	}
 
 	argvi := req.argv.Interface()
 	if req.argv.Type().Kind() != reflect.Ptr {
@@ -271,11 +615,64 @@ func (server *Server) readRequest(cc codec.Codec) (*request, error) {
 	}
 	if err = cc.ReadBody(argvi); err != nil {
 		log.Println("rpc服务读取请求体错误", err)
-		return req, err
+		return req, Errorf(CodeInvalidArgument, "rpc服务读取请求体错误 %s", err)
 	}
 	return req, nil
 }
 
+// 处理流式请求，流式方法不受处理超时限制
+func (server *Server) handleStream(sc *serverConn, req *request, st *serverStream) {
+	defer sc.wg.Done()
+	//并发限制只约束流的建立，长期存在的流（如订阅）不占用普通请求的额度
+	release, err := server.admit(st.ctx, sc, req)
+	if err != nil {
+		sc.removeStream(st.seq)
+		st.cancel()
+		setError(req.h, err)
+		server.sendResponse(sc.cc, req.h, invalidRequest, &sc.sending)
+		return
+	}
+	release()
+	switch req.mtype.kind {
+	case serverStreamMethod:
+		err = req.svc.call(st.ctx, req.mtype, req.argv, reflect.ValueOf(st))
+	case clientStreamMethod:
+		err = req.svc.call(st.ctx, req.mtype, newRecvStream(req.mtype.streamArg, st), req.replyv)
+	case bidiStreamMethod:
+		err = req.svc.call(st.ctx, req.mtype, req.argv, newRecvStream(req.mtype.streamArg, st))
+	}
+	sc.removeStream(st.seq)
+	st.cancel()
+	//最终响应，标志流结束，客户端流携带唯一的响应
+	h := &codec.Header{ServiceMethod: req.h.ServiceMethod, Seq: req.h.Seq}
+	var body interface{} = invalidRequest
+	if err != nil {
+		setError(h, err)
+	} else if req.mtype.kind == clientStreamMethod {
+		body = req.replyv.Interface()
+	}
+	server.sendResponse(sc.cc, h, body, &sc.sending)
+}
+
+// 把错误写入响应头，*Error的错误码等信息一并传给客户端
+func setError(h *codec.Header, err error) {
+	h.Error = err.Error()
+	var e *Error
+	switch {
+	case errors.As(err, &e):
+	case errors.Is(err, context.Canceled):
+		e = &Error{Code: CodeCanceled}
+	case errors.Is(err, context.DeadlineExceeded):
+		e = &Error{Code: CodeTimeout}
+	default:
+		return
+	}
+	h.Code = uint32(e.Code)
+	h.Details = e.Details
+	h.Retryable = e.Retryable
+	h.RetryAfter = e.RetryAfter
+}
+
 // 发送请求
 func (server *Server) sendResponse(cc codec.Codec, h *codec.Header, body interface{}, sending *sync.Mutex) {
 	sending.Lock()
@@ -286,15 +683,26 @@ func (server *Server) sendResponse(cc codec.Codec, h *codec.Header, body interfa
 }
 
 // 处理请求
-func (server *Server) handlerRequest(cc codec.Codec, req *request, sending *sync.Mutex, wg *sync.WaitGroup, timeout time.Duration) {
-	defer wg.Done()
-	called := make(chan struct{})
-	sent := make(chan struct{})
+func (server *Server) handlerRequest(sc *serverConn, req *request, timeout time.Duration) {
+	defer sc.wg.Done()
+	cc, sending := sc.cc, &sc.sending
+	//带缓冲，超时返回后处理协程不会阻塞泄漏
+	called := make(chan struct{}, 1)
+	sent := make(chan struct{}, 1)
+	//超时后不再排队等待并发额度
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	go func() {
-		err := req.svc.call(req.mtype, req.argv, req.replyv)
+		release, err := server.admit(ctx, sc, req)
+		if err == nil {
+			err = req.svc.call(sc.callContext(ctx, req.h), req.mtype, req.argv, req.replyv)
+			release()
+		}
+		//方法真正返回后才归还额度
+		sc.release()
 		called <- struct{}{}
 		if err != nil {
-			req.h.Error = err.Error()
+			setError(req.h, err)
 			server.sendResponse(cc, req.h, invalidRequest, sending)
 			sent <- struct{}{}
 			return
@@ -309,13 +717,33 @@ func (server *Server) handlerRequest(cc codec.Codec, req *request, sending *sync
 	}
 	select {
 	case <-time.After(timeout):
-		req.h.Error = fmt.Sprintf("服务端处理超时 %s", timeout)
+		setError(req.h, Errorf(CodeTimeout, "服务端处理超时 %s", timeout))
 		server.sendResponse(cc, req.h, invalidRequest, sending)
 	case <-called:
 		<-sent
 	}
 }
 
+// 处理单向请求，不发送响应，处理超时只限制等待并发额度的时间
+func (server *Server) handleNotify(sc *serverConn, req *request, timeout time.Duration) {
+	defer sc.wg.Done()
+	defer sc.release()
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	release, err := server.admit(ctx, sc, req)
+	if err == nil {
+		err = req.svc.call(sc.callContext(ctx, req.h), req.mtype, req.argv, req.replyv)
+		release()
+	}
+	if err != nil {
+		log.Printf("rpc server: 单向请求%s出错: %s", req.h.ServiceMethod, err)
+	}
+}
+
 // http响应服务
 func (server *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	if req.Method != "CONNECT" {
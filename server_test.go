@@ -0,0 +1,118 @@
+package GeeRPC_test
+
+import (
+	GeeRPC "codec"
+	"codec/client"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// 在随机端口启动服务端，注册rcvrs，测试结束时关闭监听
+func startServer(t *testing.T, setup func(*GeeRPC.Server), rcvrs ...interface{}) string {
+	t.Helper()
+	server := GeeRPC.NewServer()
+	if setup != nil {
+		setup(server)
+	}
+	for _, rcvr := range rcvrs {
+		if err := server.Register(rcvr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go server.Accept(l)
+	return l.Addr().String()
+}
+
+func dial(t *testing.T, addr string, opts ...*GeeRPC.Option) *client.Client {
+	t.Helper()
+	c, err := client.Dial("tcp", addr, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+type Arith struct{}
+
+func (a *Arith) Add(args [2]int, reply *int) error {
+	*reply = args[0] + args[1]
+	return nil
+}
+
+func (a *Arith) Div(args [2]int, reply *int) error {
+	if args[1] == 0 {
+		return GeeRPC.Errorf(GeeRPC.CodeInvalidArgument, "divide by zero")
+	}
+	*reply = args[0] / args[1]
+	return nil
+}
+
+func (a *Arith) Fail(msg string, reply *int) error {
+	return errors.New(msg)
+}
+
+func (a *Arith) Sleep(d time.Duration, reply *int) error {
+	time.Sleep(d)
+	return nil
+}
+
+func TestCall(t *testing.T) {
+	addr := startServer(t, nil, &Arith{})
+	c := dial(t, addr)
+	slow := dial(t, addr, &GeeRPC.Option{HandleTimeout: 20 * time.Millisecond})
+	tests := []struct {
+		name    string
+		client  *client.Client
+		method  string
+		args    interface{}
+		timeout time.Duration
+		want    int
+		wantErr bool
+		code    GeeRPC.Code
+		is      error
+	}{
+		{"unary", c, "Arith.Add", [2]int{1, 2}, 0, 3, false, 0, nil},
+		{"coded error", c, "Arith.Div", [2]int{1, 0}, 0, 0, true, GeeRPC.CodeInvalidArgument, GeeRPC.ErrInvalidArgument},
+		{"plain error", c, "Arith.Fail", "boom", 0, 0, true, GeeRPC.CodeUnknown, nil},
+		{"method not found", c, "Arith.Mul", [2]int{1, 2}, 0, 0, true, GeeRPC.CodeNotFound, GeeRPC.ErrNotFound},
+		{"service not found", c, "Calc.Add", [2]int{1, 2}, 0, 0, true, GeeRPC.CodeNotFound, GeeRPC.ErrNotFound},
+		{"client timeout", c, "Arith.Sleep", 200 * time.Millisecond, 20 * time.Millisecond, 0, true, GeeRPC.CodeTimeout, GeeRPC.ErrTimeout},
+		{"server timeout", slow, "Arith.Sleep", 200 * time.Millisecond, 0, 0, true, GeeRPC.CodeTimeout, GeeRPC.ErrTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			var reply int
+			err := tt.client.Call(ctx, tt.method, tt.args, &reply)
+			if !tt.wantErr {
+				if err != nil || reply != tt.want {
+					t.Fatalf("reply = %d, err = %v; want %d", reply, err, tt.want)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("err = nil, want code %s", tt.code)
+			}
+			if got := GeeRPC.CodeOf(err); got != tt.code {
+				t.Errorf("code = %s, want %s (err %v)", got, tt.code, err)
+			}
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.is)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package GeeRPC
+
+import (
+	"codec/codec"
+	"codec/flow"
+	"context"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// 服务端流，流式方法通过Send多次返回结果
+// 方法签名：func (rcvr *T) MethodName(argv T1, stream ServerStream) error
+type ServerStream interface {
+	Send(reply interface{}) error //发送一条结果
+	Context() context.Context     //客户端取消或连接断开时结束
+}
+
+var typeOfServerStream = reflect.TypeOf((*ServerStream)(nil)).Elem()
+
+// 客户端流，Recv在客户端发送完毕后返回io.EOF
+// 方法签名：func (rcvr *T) MethodName(stream *ClientStream[T1], reply *T2) error
+type ClientStream[T any] struct {
+	st *serverStream
+}
+
+func (s *ClientStream[T]) Recv() (T, error) { return recvAs[T](s.st) }
+
+func (s *ClientStream[T]) Context() context.Context { return s.st.ctx }
+
+func (s *ClientStream[T]) streamKind() methodKind { return clientStreamMethod }
+
+func (s *ClientStream[T]) msgType() reflect.Type { return reflect.TypeOf((*T)(nil)).Elem() }
+
+func (s *ClientStream[T]) bind(st *serverStream) { s.st = st }
+
+// 双向流，收发互不阻塞
+// 方法签名：func (rcvr *T) MethodName(argv T1, stream *BidiStream[T2]) error
+type BidiStream[T any] struct {
+	st *serverStream
+}
+
+func (s *BidiStream[T]) Recv() (T, error) { return recvAs[T](s.st) }
+
+func (s *BidiStream[T]) Send(reply interface{}) error { return s.st.Send(reply) }
+
+func (s *BidiStream[T]) Context() context.Context { return s.st.ctx }
+
+func (s *BidiStream[T]) streamKind() methodKind { return bidiStreamMethod }
+
+func (s *BidiStream[T]) msgType() reflect.Type { return reflect.TypeOf((*T)(nil)).Elem() }
+
+func (s *BidiStream[T]) bind(st *serverStream) { s.st = st }
+
+// 能接收客户端消息的流参数
+type recvStream interface {
+	streamKind() methodKind
+	msgType() reflect.Type
+	bind(st *serverStream)
+}
+
+var typeOfRecvStream = reflect.TypeOf((*recvStream)(nil)).Elem()
+
+// 判断参数是否为接收流，返回方法类型与消息类型
+func parseRecvStream(t reflect.Type) (methodKind, reflect.Type, bool) {
+	if t.Kind() != reflect.Ptr || !t.Implements(typeOfRecvStream) {
+		return unaryMethod, nil, false
+	}
+	rs := reflect.New(t.Elem()).Interface().(recvStream)
+	return rs.streamKind(), rs.msgType(), true
+}
+
+// 为单次请求创建流参数
+func newRecvStream(t reflect.Type, st *serverStream) reflect.Value {
+	v := reflect.New(t.Elem())
+	v.Interface().(recvStream).bind(st)
+	return v
+}
+
+func recvAs[T any](st *serverStream) (T, error) {
+	var msg T
+	v, err := st.recv()
+	if err != nil {
+		return msg, err
+	}
+	return v.Interface().(T), nil
+}
+
+type serverStream struct {
+	sc            *serverConn
+	seq           uint64
+	serviceMethod string
+	ctx           context.Context
+	cancel        context.CancelFunc
+	window        *flow.Window //发送额度
+	ack           *flow.Ack    //接收消费计数
+	msgType       reflect.Type //接收消息类型，服务端流为nil
+
+	mu         sync.Mutex
+	queue      []reflect.Value //已收到未读取的消息
+	recvClosed bool            //客户端已发送完毕
+	notify     chan struct{}   //有新消息到达
+}
+
+var _ ServerStream = (*serverStream)(nil)
+
+func newServerStream(sc *serverConn, req *request) *serverStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &serverStream{
+		sc:            sc,
+		seq:           req.h.Seq,
+		serviceMethod: req.h.ServiceMethod,
+		ctx:           ctx,
+		cancel:        cancel,
+		window:        flow.NewWindow(sc.window),
+		ack:           flow.NewAck(sc.window),
+		msgType:       req.mtype.msgType,
+		notify:        make(chan struct{}, 1),
+	}
+}
+
+func (st *serverStream) Context() context.Context {
+	return st.ctx
+}
+
+// 发送流数据帧，额度耗尽时等待客户端消费
+func (st *serverStream) Send(reply interface{}) error {
+	if err := st.window.Acquire(st.ctx); err != nil {
+		return err
+	}
+	return st.sc.write(&codec.Header{
+		ServiceMethod: st.serviceMethod,
+		Seq:           st.seq,
+		Frame:         codec.FrameStream,
+	}, reply)
+}
+
+// 读取一条客户端消息，由连接的读协程调用
+func (st *serverStream) receive(cc codec.Codec) error {
+	v := reflect.New(st.msgType)
+	if err := cc.ReadBody(v.Interface()); err != nil {
+		return err
+	}
+	st.mu.Lock()
+	st.queue = append(st.queue, v.Elem())
+	st.mu.Unlock()
+	st.wake()
+	return nil
+}
+
+// 客户端半关闭
+func (st *serverStream) closeRecv() {
+	st.mu.Lock()
+	st.recvClosed = true
+	st.mu.Unlock()
+	st.wake()
+}
+
+func (st *serverStream) wake() {
+	select {
+	case st.notify <- struct{}{}:
+	default:
+	}
+}
+
+// 取出一条消息，消费过半窗口后归还客户端额度
+func (st *serverStream) recv() (reflect.Value, error) {
+	for {
+		st.mu.Lock()
+		if len(st.queue) > 0 {
+			v := st.queue[0]
+			st.queue = st.queue[1:]
+			st.mu.Unlock()
+			if n := st.ack.Consume(); n > 0 {
+				_ = st.sc.write(&codec.Header{
+					ServiceMethod: st.serviceMethod,
+					Seq:           st.seq,
+					Frame:         codec.FrameWindow,
+				}, n)
+			}
+			return v, nil
+		}
+		closed := st.recvClosed
+		st.mu.Unlock()
+		if closed {
+			return reflect.Value{}, io.EOF
+		}
+		select {
+		case <-st.notify:
+		case <-st.ctx.Done():
+			return reflect.Value{}, st.ctx.Err()
+		}
+	}
+}
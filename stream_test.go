@@ -0,0 +1,190 @@
+package GeeRPC_test
+
+import (
+	GeeRPC "codec"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+type Streamer struct {
+	ended chan error //Watch结束时的返回值
+}
+
+// 依次发送0..n-1
+func (s *Streamer) Count(n int, stream GeeRPC.ServerStream) error {
+	for i := 0; i < n; i++ {
+		if err := stream.Send(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 累加客户端发送的所有数
+func (s *Streamer) Sum(stream *GeeRPC.ClientStream[int], reply *int) error {
+	for {
+		n, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		*reply += n
+	}
+}
+
+// 每收到一个数返回它的factor倍
+func (s *Streamer) Scale(factor int, stream *GeeRPC.BidiStream[int]) error {
+	for {
+		n, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(n * factor); err != nil {
+			return err
+		}
+	}
+}
+
+// 持续发送直到流被取消
+func (s *Streamer) Watch(_ int, stream GeeRPC.ServerStream) error {
+	for i := 0; ; i++ {
+		if err := stream.Send(i); err != nil {
+			s.ended <- err
+			return err
+		}
+		select {
+		case <-stream.Context().Done():
+			s.ended <- stream.Context().Err()
+			return nil
+		default:
+		}
+	}
+}
+
+// 消息数远超窗口，发送方必须等待对端归还额度
+func TestStreamsPastWindow(t *testing.T) {
+	const window, n = 4, 100
+	addr := startServer(t, nil, &Streamer{})
+	c := dial(t, addr, &GeeRPC.Option{StreamWindow: window})
+	want := 0
+	for i := 0; i < n; i++ {
+		want += i
+	}
+	tests := []struct {
+		name string
+		run  func(ctx context.Context) (int, error)
+	}{
+		{"server stream", func(ctx context.Context) (int, error) {
+			var msg, sum, count int
+			st := c.Stream(ctx, "Streamer.Count", n, &msg)
+			for st.Next() {
+				if msg != count {
+					t.Errorf("message %d = %d", count, msg)
+				}
+				sum += msg
+				count++
+				//慢速消费，服务端会耗尽额度
+				if count%window == 0 {
+					time.Sleep(time.Millisecond)
+				}
+			}
+			if count != n {
+				t.Errorf("received %d messages, want %d", count, n)
+			}
+			return sum, st.Err()
+		}},
+		{"client stream", func(ctx context.Context) (int, error) {
+			var sum int
+			st := c.ClientStream(ctx, "Streamer.Sum", &sum)
+			for i := 0; i < n; i++ {
+				if err := st.Send(i); err != nil {
+					return 0, err
+				}
+			}
+			return sum, st.CloseAndRecv()
+		}},
+		{"bidi stream", func(ctx context.Context) (int, error) {
+			var msg, sum int
+			st := c.BidiStream(ctx, "Streamer.Scale", 1, &msg)
+			sendErr := make(chan error, 1)
+			go func() {
+				for i := 0; i < n; i++ {
+					if err := st.Send(i); err != nil {
+						sendErr <- err
+						return
+					}
+				}
+				sendErr <- st.CloseSend()
+			}()
+			for st.Next() {
+				sum += msg
+			}
+			if err := <-sendErr; err != nil {
+				return 0, err
+			}
+			return sum, st.Err()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			sum, err := tt.run(ctx)
+			if err != nil || sum != want {
+				t.Fatalf("sum = %d, err = %v; want %d", sum, err, want)
+			}
+		})
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	s := &Streamer{ended: make(chan error, 2)}
+	addr := startServer(t, nil, s)
+	c := dial(t, addr, &GeeRPC.Option{StreamWindow: 4})
+	tests := []struct {
+		name string
+		stop func(cancel context.CancelFunc, close func() error)
+		err  error //客户端Err()应满足的错误，nil表示正常结束
+	}{
+		{"ctx canceled", func(cancel context.CancelFunc, _ func() error) { cancel() }, GeeRPC.ErrCanceled},
+		{"Close", func(_ context.CancelFunc, close func() error) { _ = close() }, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var msg int
+			st := c.Stream(ctx, "Streamer.Watch", 0, &msg)
+			for i := 0; i < 10; i++ {
+				if !st.Next() {
+					t.Fatalf("stream ended early: %v", st.Err())
+				}
+			}
+			tt.stop(cancel, st.Close)
+			for st.Next() {
+			}
+			if err := st.Err(); (tt.err == nil) != (err == nil) || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Fatalf("Err() = %v, want %v", err, tt.err)
+			}
+			//服务端的流随之结束
+			select {
+			case <-s.ended:
+			case <-time.After(5 * time.Second):
+				t.Fatal("server stream still running after cancel")
+			}
+			//连接仍然可用
+			var reply int
+			if st := c.Stream(context.Background(), "Streamer.Count", 3, &reply); !st.Next() {
+				t.Fatalf("stream after cancel: %v", st.Err())
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+)
+
+// 设置实例选择器，替换NewXClient时按选择策略创建的内置选择器
+func (xc *XClient) SetSelector(s Selector) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	xc.selector = s
+}
+
+func (xc *XClient) getSelector() Selector {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	return xc.selector
+}
+
+// 从服务发现拉取实例列表，交给选择器选出实例
+func (xc *XClient) selectServer(ctx context.Context, serviceMethod string, args interface{}) (string, error) {
+	servers, err := xc.d.GetAll()
+	if err != nil {
+		return "", err
+	}
+	if len(servers) == 0 {
+		return "", errors.New("没有找到任何服务")
+	}
+	if servers, err = xc.healthyServers(servers); err != nil {
+		return "", err
+	}
+	md, _ := xc.d.(interface {
+		Metadata(addr string) map[string]string
+	})
+	infos := make([]ServerInfo, len(servers))
+	for i, s := range servers {
+		infos[i] = ServerInfo{Addr: s, Pending: xc.pending(s)}
+		if md != nil {
+			infos[i].Metadata = md.Metadata(s)
+		}
+	}
+	return xc.getSelector().Select(ctx, infos, CallInfo{ServiceMethod: serviceMethod, Args: args})
+}
+
+// 过滤掉健康检查未通过、熔断中与被驱逐的实例
+func (xc *XClient) healthyServers(servers []string) ([]string, error) {
+	if h := xc.getHealth(); h != nil {
+		servers = h.filter(servers)
+	}
+	servers, err := xc.availableServers(servers)
+	if err != nil {
+		return nil, err
+	}
+	if o := xc.getOutliers(); o != nil {
+		servers = o.filter(servers)
+	}
+	return servers, nil
+}
+
+// 实例上未完成的请求数，尚未建立连接时为0
+func (xc *XClient) pending(rpcAddr string) int {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	pool := xc.clients[rpcAddr]
+	if pool == nil {
+		return 0
+	}
+	return pool.pending()
+}
+
+// 选择未完成请求最少的实例
+type leastPendingSelector struct{ noFeedback }
+
+func (*leastPendingSelector) Select(_ context.Context, servers []ServerInfo, _ CallInfo) (string, error) {
+	//从随机位置开始遍历，未完成请求数相同时避免总选第一个
+	n := len(servers)
+	start := rand.Intn(n)
+	best := servers[start]
+	for i := 1; i < n; i++ {
+		if s := servers[(start+i)%n]; s.Pending < best.Pending {
+			best = s
+		}
+	}
+	return best.Addr, nil
+}
+
+// 随机取两个实例，选择未完成请求较少的
+type powerOfTwoSelector struct{ noFeedback }
+
+func (*powerOfTwoSelector) Select(_ context.Context, servers []ServerInfo, _ CallInfo) (string, error) {
+	n := len(servers)
+	a := servers[rand.Intn(n)]
+	if n == 1 {
+		return a.Addr, nil
+	}
+	b := servers[rand.Intn(n-1)]
+	if b.Addr == a.Addr {
+		b = servers[n-1]
+	}
+	if b.Pending < a.Pending {
+		return b.Addr, nil
+	}
+	return a.Addr, nil
+}
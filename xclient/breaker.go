@@ -0,0 +1,204 @@
+package xclient
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 熔断器状态
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota //正常放行
+	BreakerOpen                         //熔断中，拒绝请求
+	BreakerHalfOpen                     //冷却结束，放行少量探测请求
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// 熔断策略，按实例统计，为0的字段取DefaultBreakerPolicy中的值
+type BreakerPolicy struct {
+	FailureRatio     float64       //统计窗口内失败比例达到该值时熔断
+	MinRequests      int           //统计窗口内请求数少于该值时不熔断
+	Window           time.Duration //统计窗口，窗口结束后清零重新统计
+	Cooldown         time.Duration //熔断后经过该时间进入半开状态
+	HalfOpenRequests int           //半开状态放行的探测请求数，全部成功后恢复，不大于0时为1
+}
+
+// 默认熔断策略
+var DefaultBreakerPolicy = &BreakerPolicy{
+	FailureRatio:     0.5,
+	MinRequests:      10,
+	Window:           time.Second * 10,
+	Cooldown:         time.Second * 5,
+	HalfOpenRequests: 1,
+}
+
+var ErrBreakerOpen = errors.New("rpc xclient: circuit breaker open")
+
+type breaker struct {
+	mu        sync.Mutex
+	state     BreakerState
+	start     time.Time //当前统计窗口的开始时间
+	requests  int
+	failures  int
+	openedAt  time.Time
+	probes    int //半开状态已放行的探测请求数
+	successes int //半开状态成功的探测请求数
+}
+
+// 熔断中且未冷却，或半开状态探测名额已用完时返回false，不改变状态
+func (b *breaker) available(p *BreakerPolicy, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		return now.Sub(b.openedAt) >= p.Cooldown
+	case BreakerHalfOpen:
+		return b.probes < halfOpenRequests(p)
+	}
+	return true
+}
+
+// 请求发出前调用，返回false时拒绝请求
+func (b *breaker) allow(p *BreakerPolicy, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen {
+		if now.Sub(b.openedAt) < p.Cooldown {
+			return false
+		}
+		b.state, b.probes, b.successes = BreakerHalfOpen, 0, 0
+	}
+	if b.state == BreakerHalfOpen {
+		if b.probes >= halfOpenRequests(p) {
+			return false
+		}
+		b.probes++
+	}
+	return true
+}
+
+// 探测请求被调用方取消，归还探测名额
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// 记录请求结果
+func (b *breaker) record(p *BreakerPolicy, failed bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerHalfOpen:
+		if failed {
+			b.state, b.openedAt = BreakerOpen, now
+			return
+		}
+		b.successes++
+		if b.successes >= halfOpenRequests(p) {
+			b.state, b.start, b.requests, b.failures = BreakerClosed, now, 0, 0
+		}
+	case BreakerClosed:
+		if now.Sub(b.start) > p.Window {
+			b.start, b.requests, b.failures = now, 0, 0
+		}
+		b.requests++
+		if failed {
+			b.failures++
+		}
+		if b.requests >= p.MinRequests && float64(b.failures) >= p.FailureRatio*float64(b.requests) {
+			b.state, b.openedAt = BreakerOpen, now
+		}
+	}
+}
+
+func halfOpenRequests(p *BreakerPolicy) int {
+	if p.HalfOpenRequests <= 0 {
+		return 1
+	}
+	return p.HalfOpenRequests
+}
+
+// 设置熔断策略，nil表示不熔断
+func (xc *XClient) SetBreakerPolicy(p *BreakerPolicy) {
+	if p != nil {
+		//复制一份补全默认值，避免0值策略在第一次成功调用后就熔断
+		policy := *p
+		d := DefaultBreakerPolicy
+		if policy.FailureRatio <= 0 {
+			policy.FailureRatio = d.FailureRatio
+		}
+		if policy.MinRequests <= 0 {
+			policy.MinRequests = d.MinRequests
+		}
+		if policy.Window <= 0 {
+			policy.Window = d.Window
+		}
+		if policy.Cooldown <= 0 {
+			policy.Cooldown = d.Cooldown
+		}
+		p = &policy
+	}
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	xc.breakerPolicy = p
+}
+
+func (xc *XClient) getBreakerPolicy() *BreakerPolicy {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	return xc.breakerPolicy
+}
+
+func (xc *XClient) breakerFor(rpcAddr string) *breaker {
+	v, _ := xc.breakers.LoadOrStore(rpcAddr, &breaker{start: time.Now()})
+	return v.(*breaker)
+}
+
+// 实例的熔断器状态
+func (xc *XClient) BreakerState(rpcAddr string) BreakerState {
+	v, ok := xc.breakers.Load(rpcAddr)
+	if !ok {
+		return BreakerClosed
+	}
+	b := v.(*breaker)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// 过滤掉熔断中的实例，全部熔断时返回ErrBreakerOpen
+func (xc *XClient) availableServers(servers []string) ([]string, error) {
+	p := xc.getBreakerPolicy()
+	if p == nil {
+		return servers, nil
+	}
+	now := time.Now()
+	available := make([]string, 0, len(servers))
+	for _, s := range servers {
+		if xc.breakerFor(s).available(p, now) {
+			available = append(available, s)
+		}
+	}
+	if len(available) == 0 && len(servers) > 0 {
+		return nil, fmt.Errorf("%w: all %d servers", ErrBreakerOpen, len(servers))
+	}
+	return available, nil
+}
@@ -0,0 +1,24 @@
+package xclient_test
+
+import (
+	"codec/xclient"
+	"context"
+	"testing"
+	"time"
+)
+
+// 只设置部分字段的策略使用默认值补全，成功的调用不会触发熔断
+func TestBreakerPolicyDefaults(t *testing.T) {
+	addrs := startServers(t, 1)
+	xc := newXClient(t, addrs)
+	xc.SetBreakerPolicy(&xclient.BreakerPolicy{Cooldown: time.Minute})
+	for i := 0; i < 3; i++ {
+		var reply int
+		if err := xc.Call(context.Background(), "Foo.Sum", [2]int{1, 2}, &reply); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		if s := xc.BreakerState(addrs[0]); s != xclient.BreakerClosed {
+			t.Fatalf("breaker = %s after %d successful calls, want closed", s, i+1)
+		}
+	}
+}
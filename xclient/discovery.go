@@ -1,80 +1,101 @@
 package xclient
 
 import (
+	"context"
 	"errors"
-	"math"
-	"math/rand"
 	"sync"
-	"time"
 )
 
 type SelectMode int
 
 const (
-	RandomSelect     SelectMode = iota // 随机选择
-	RoundRobinSelect                   //轮询策略
+	RandomSelect             SelectMode = iota // 随机选择
+	RoundRobinSelect                           //轮询策略
+	WeightedRoundRobinSelect                   //按metadata中的weight平滑加权轮询
+	LeastPendingSelect                         //选择未完成请求最少的实例
+	PowerOfTwoSelect                           //随机取两个实例，选择未完成请求较少的
+	ConsistentHashSelect                       //按路由键一致性哈希，同一个键落在同一实例
+	LatencyAwareSelect                         //优先选择平均延迟低、错误率低的实例
 )
 
 type Discovery interface {
 	Refresh() error                            //更新服务列表
 	Update(servers []string) error             //手动更新列表
-	Get(selectMode SelectMode) (string, error) // 拉取单个服务
+	Get(selectMode SelectMode) (string, error) // 拉取单个服务，XClient改用Selector选择
 	GetAll() ([]string, error)                 // 拉取全部服务
 }
 
 type MultiServersDiscovery struct {
-	r       *rand.Rand //随机数
-	mu      sync.RWMutex
-	servers []string
-	index   int //负载均衡策略
+	mu        sync.RWMutex
+	servers   []string
+	metadata  map[string]map[string]string //实例的metadata，如weight
+	selectors map[SelectMode]Selector      //Get使用的内置选择器
 }
 
 // 服务发现功能服务初始化
 func NewMultiserversDiscovery(servers []string) *MultiServersDiscovery {
-	d := &MultiServersDiscovery{
-		servers: servers,
-		r:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	return &MultiServersDiscovery{
+		servers:   servers,
+		metadata:  make(map[string]map[string]string),
+		selectors: make(map[SelectMode]Selector),
 	}
-	d.index = d.r.Intn(math.MaxInt32 - 1)
-	return d
 }
 
 var _ Discovery = (*MultiServersDiscovery)(nil)
 
-func (m MultiServersDiscovery) Refresh() error {
+func (m *MultiServersDiscovery) Refresh() error {
 	return nil
 }
 
-func (m MultiServersDiscovery) Update(servers []string) error {
+func (m *MultiServersDiscovery) Update(servers []string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.servers = servers
 	return nil
 }
 
-func (m MultiServersDiscovery) Get(mode SelectMode) (string, error) {
+func (m *MultiServersDiscovery) Get(mode SelectMode) (string, error) {
+	switch mode {
+	case RandomSelect, RoundRobinSelect, WeightedRoundRobinSelect:
+	default:
+		//其余策略依赖XClient的连接状态或调用统计
+		return "", errors.New("不支持所选模式")
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	n := len(m.servers)
-	if n == 0 {
+	if len(m.servers) == 0 {
 		return "", errors.New("没有找到任何服务")
 	}
-	switch mode {
-	case RandomSelect:
-		return m.servers[m.r.Intn(n)], nil
-	case RoundRobinSelect:
-		s := m.servers[m.index%n]
-		m.index = (m.index + 1) % n
-		return s, nil
-	default:
-		return "", errors.New("不支持所选模式")
+	s, ok := m.selectors[mode]
+	if !ok {
+		s = NewSelector(mode)
+		m.selectors[mode] = s
 	}
+	servers := make([]ServerInfo, len(m.servers))
+	for i, addr := range m.servers {
+		servers[i] = ServerInfo{Addr: addr, Metadata: m.metadata[addr]}
+	}
+	return s.Select(context.Background(), servers, CallInfo{})
 }
 
-func (m MultiServersDiscovery) GetAll() ([]string, error) {
+func (m *MultiServersDiscovery) GetAll() ([]string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	servers := make([]string, len(m.servers), len(m.servers))
 	copy(servers, m.servers)
 	return servers, nil
 }
+
+// 设置实例的metadata
+func (m *MultiServersDiscovery) SetMetadata(addr string, md map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.metadata[addr] = md
+}
+
+// 获取实例的metadata
+func (m *MultiServersDiscovery) Metadata(addr string) map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.metadata[addr]
+}
@@ -2,6 +2,7 @@ package xclient
 
 import (
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -48,13 +49,31 @@ func (d *GeeRegistryDiscovery) Refresh() error {
 	if err != nil {
 		return err
 	}
+	_ = resp.Body.Close()
 	servers := strings.Split(resp.Header.Get("X-Geerpc-Servers"), ",")
-	d.servers = make([]string, len(servers))
+	d.servers = make([]string, 0, len(servers))
 	for _, server := range servers {
 		if strings.TrimSpace(server) != "" {
 			d.servers = append(d.servers, strings.TrimSpace(server))
 		}
 	}
+	//"地址;metadata"
+	d.metadata = make(map[string]map[string]string)
+	for _, line := range resp.Header.Values("X-Geerpc-Metadata") {
+		addr, encoded, ok := strings.Cut(line, ";")
+		if !ok {
+			continue
+		}
+		values, err := url.ParseQuery(encoded)
+		if err != nil {
+			continue
+		}
+		md := make(map[string]string, len(values))
+		for k := range values {
+			md[k] = values.Get(k)
+		}
+		d.metadata[addr] = md
+	}
 	d.lastUpdate = time.Now()
 	return nil
 }
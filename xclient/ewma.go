@@ -0,0 +1,111 @@
+package xclient
+
+import (
+	GeeRPC "codec"
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	ewmaAlpha   = 0.3  //新样本的权重
+	exploreRate = 0.05 //随机探测其他实例的概率，使变快的实例有机会被重新选中
+	//还没有成功调用的实例按该延迟计算，避免连接失败等快速返回的错误使其显得很快
+	failurePenalty = time.Second
+)
+
+// 实例的指数加权移动平均延迟与错误率
+type addrStats struct {
+	mu      sync.Mutex
+	latency float64 //成功调用的平均延迟，纳秒
+	errRate float64 //失败调用的比例
+	seen    bool    //是否有过调用
+	ok      bool    //是否有过成功调用
+}
+
+func (s *addrStats) add(d time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := 0.0
+	if failed {
+		e = 1
+	}
+	if !s.seen {
+		s.errRate, s.seen = e, true
+	} else {
+		s.errRate += ewmaAlpha * (e - s.errRate)
+	}
+	//失败的调用往往很快返回，不计入延迟
+	if failed {
+		return
+	}
+	if !s.ok {
+		s.latency, s.ok = float64(d), true
+		return
+	}
+	s.latency += ewmaAlpha * (float64(d) - s.latency)
+}
+
+// 延迟按错误率放大，没有样本的实例得分为0以便尽快被探测
+func (s *addrStats) score() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.seen {
+		return 0
+	}
+	latency := s.latency
+	if !s.ok {
+		latency = float64(failurePenalty)
+	}
+	return latency / (1 - s.errRate + 1e-3)
+}
+
+// 判断错误是否说明实例不健康，业务错误不计入
+func unhealthy(err error) bool {
+	var de *dialError
+	if errors.As(err, &de) {
+		return true
+	}
+	switch GeeRPC.CodeOf(err) {
+	case GeeRPC.CodeTimeout, GeeRPC.CodeOverloaded, GeeRPC.CodeUnavailable, GeeRPC.CodeInternal:
+		return true
+	}
+	return false
+}
+
+// 延迟感知选择器，记录各实例的平均延迟与错误率，选择得分最低的实例
+type latencySelector struct {
+	stats sync.Map //地址 -> *addrStats
+}
+
+func (s *latencySelector) Feedback(addr string, _ CallInfo, d time.Duration, err error) {
+	if err != nil && !unhealthy(err) {
+		return
+	}
+	v, _ := s.stats.LoadOrStore(addr, &addrStats{})
+	v.(*addrStats).add(d, err != nil)
+}
+
+// 按exploreRate随机探测，其余时候选择得分最低的实例
+func (s *latencySelector) Select(_ context.Context, servers []ServerInfo, _ CallInfo) (string, error) {
+	n := len(servers)
+	if rand.Float64() < exploreRate {
+		return servers[rand.Intn(n)].Addr, nil
+	}
+	//从随机位置开始遍历，得分相同时避免总选第一个
+	start := rand.Intn(n)
+	best, least := "", -1.0
+	for i := 0; i < n; i++ {
+		addr := servers[(start+i)%n].Addr
+		score := 0.0
+		if v, ok := s.stats.Load(addr); ok {
+			score = v.(*addrStats).score()
+		}
+		if least < 0 || score < least {
+			best, least = addr, score
+		}
+	}
+	return best, nil
+}
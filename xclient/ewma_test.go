@@ -0,0 +1,80 @@
+package xclient_test
+
+import (
+	GeeRPC "codec"
+	"codec/xclient"
+	"context"
+	"testing"
+	"time"
+)
+
+type feedback struct {
+	addr  string
+	d     time.Duration
+	err   error
+	times int
+}
+
+func TestLatencyAwareSelect(t *testing.T) {
+	unavailable := GeeRPC.Errorf(GeeRPC.CodeUnavailable, "connection reset")
+	invalid := GeeRPC.Errorf(GeeRPC.CodeInvalidArgument, "bad request")
+	tests := []struct {
+		name     string
+		feedback []feedback
+		want     string //应被绝大多数调用选中的实例
+	}{
+		{"lower latency", []feedback{{"a", 50 * time.Millisecond, nil, 10}, {"b", time.Millisecond, nil, 10}}, "b"},
+		{"fast but failing", []feedback{{"a", time.Millisecond, nil, 1}, {"a", 0, unavailable, 10}, {"b", 5 * time.Millisecond, nil, 10}}, "b"},
+		{"only failures", []feedback{{"a", 0, unavailable, 3}, {"b", 500 * time.Millisecond, nil, 3}}, "b"},
+		{"application errors do not count", []feedback{{"a", time.Millisecond, nil, 1}, {"a", 0, invalid, 10}, {"b", 5 * time.Millisecond, nil, 10}}, "a"},
+		{"unseen instance is probed", []feedback{{"a", time.Millisecond, nil, 10}}, "b"},
+		//平均值随新样本变化，变慢的实例让出流量
+		{"adapts", []feedback{{"a", time.Millisecond, nil, 10}, {"b", 5 * time.Millisecond, nil, 10}, {"a", 50 * time.Millisecond, nil, 10}}, "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := xclient.NewSelector(xclient.LatencyAwareSelect)
+			call := xclient.CallInfo{ServiceMethod: "Foo.Sum"}
+			for _, f := range tt.feedback {
+				for i := 0; i < f.times; i++ {
+					s.Feedback(f.addr, call, f.d, f.err)
+				}
+			}
+			counts := pick(t, s, serverInfos("a", "b"), call, 1000)
+			//5%的调用随机探测，其余都应选中得分最低的实例
+			if counts[tt.want] < 900 {
+				t.Fatalf("counts = %v, want most calls on %s", counts, tt.want)
+			}
+			if counts[tt.want] == 1000 {
+				t.Fatalf("counts = %v, want some exploration", counts)
+			}
+		})
+	}
+}
+
+// XClient把每次调用的耗时反馈给选择器，预热后调用集中到快的实例
+func TestLatencyAwareXClient(t *testing.T) {
+	fast, slow := startServer(t, Foo{}), startServer(t, Foo{delay: 50 * time.Millisecond})
+	xc := xclient.NewXClient(xclient.NewMultiserversDiscovery([]string{fast, slow}), xclient.LatencyAwareSelect, nil)
+	t.Cleanup(func() { _ = xc.Close() })
+	call := func() time.Duration {
+		start := time.Now()
+		var reply int
+		if err := xc.Call(context.Background(), "Foo.Sum", [2]int{1, 2}, &reply); err != nil {
+			t.Fatal(err)
+		}
+		return time.Since(start)
+	}
+	for i := 0; i < 5; i++ {
+		call()
+	}
+	slowCalls := 0
+	for i := 0; i < 40; i++ {
+		if call() >= 50*time.Millisecond {
+			slowCalls++
+		}
+	}
+	if slowCalls > 8 {
+		t.Fatalf("%d of 40 calls went to the slow instance", slowCalls)
+	}
+}
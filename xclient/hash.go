@@ -0,0 +1,129 @@
+package xclient
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// 每个实例在哈希环上的虚拟节点数
+const defaultReplicas = 160
+
+// 参数实现该接口时，一致性哈希按返回的键选择实例
+type RoutingKeyer interface {
+	RoutingKey() string
+}
+
+type routingKey struct{}
+
+// 把路由键附加到ctx，优先于参数中的路由键
+func WithRoutingKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, routingKey{}, key)
+}
+
+// 取出请求的路由键，先查ctx再查参数
+func routingKeyOf(ctx context.Context, args interface{}) (string, bool) {
+	if key, ok := ctx.Value(routingKey{}).(string); ok {
+		return key, true
+	}
+	if k, ok := args.(RoutingKeyer); ok {
+		return k.RoutingKey(), true
+	}
+	return "", false
+}
+
+// 一致性哈希环，实例增减时只有相邻区间的键会改变映射
+type hashRing struct {
+	servers []string          //构建环时的服务列表，用于判断是否需要重建
+	keys    []uint32          //排序后的虚拟节点哈希值
+	nodes   map[uint32]string //虚拟节点 -> 实例地址
+}
+
+func newHashRing(servers []string, replicas int) *hashRing {
+	r := &hashRing{
+		servers: append([]string(nil), servers...),
+		keys:    make([]uint32, 0, len(servers)*replicas),
+		nodes:   make(map[uint32]string, len(servers)*replicas),
+	}
+	for _, s := range servers {
+		for i := 0; i < replicas; i++ {
+			h := hashKey(strconv.Itoa(i) + "#" + s)
+			if _, ok := r.nodes[h]; ok {
+				continue
+			}
+			r.nodes[h] = s
+			r.keys = append(r.keys, h)
+		}
+	}
+	sort.Slice(r.keys, func(i, j int) bool { return r.keys[i] < r.keys[j] })
+	return r
+}
+
+// 服务列表与构建时一致，与顺序无关
+func (r *hashRing) sameServers(servers []string) bool {
+	if len(r.servers) != len(servers) {
+		return false
+	}
+	set := make(map[string]bool, len(servers))
+	for _, s := range r.servers {
+		set[s] = true
+	}
+	for _, s := range servers {
+		if !set[s] {
+			return false
+		}
+	}
+	return true
+}
+
+// FNV-1a哈希；crc32对只差几个字符的地址与键分布不均，会使个别实例承担近一倍的键
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum32()
+}
+
+// 顺时针找到键所在的第一个虚拟节点
+func (r *hashRing) get(key string) string {
+	h := hashKey(key)
+	i := sort.Search(len(r.keys), func(i int) bool { return r.keys[i] >= h })
+	return r.nodes[r.keys[i%len(r.keys)]]
+}
+
+// 一致性哈希选择器，按路由键在哈希环上选择实例，没有路由键时随机选择
+type consistentHashSelector struct {
+	noFeedback
+	mu       sync.Mutex
+	replicas int
+	ring     *hashRing //服务列表变化时重建
+}
+
+// 创建一致性哈希选择器，replicas为每个实例的虚拟节点数，不大于0时使用默认值
+func NewConsistentHashSelector(replicas int) Selector {
+	if replicas <= 0 {
+		replicas = defaultReplicas
+	}
+	return &consistentHashSelector{replicas: replicas}
+}
+
+func (s *consistentHashSelector) Select(ctx context.Context, servers []ServerInfo, call CallInfo) (string, error) {
+	key, ok := routingKeyOf(ctx, call.Args)
+	if !ok {
+		return servers[rand.Intn(len(servers))].Addr, nil
+	}
+	addrs := make([]string, len(servers))
+	for i, server := range servers {
+		addrs[i] = server.Addr
+	}
+	s.mu.Lock()
+	ring := s.ring
+	if ring == nil || !ring.sameServers(addrs) {
+		ring = newHashRing(addrs, s.replicas)
+		s.ring = ring
+	}
+	s.mu.Unlock()
+	return ring.get(key), nil
+}
@@ -0,0 +1,125 @@
+package xclient_test
+
+import (
+	"codec/xclient"
+	"context"
+	"fmt"
+	"testing"
+)
+
+// 地址相近的实例也应分到大致相同数量的键
+func TestConsistentHashBalance(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers []string
+	}{
+		{"4 hosts", []string{"tcp@10.0.0.1:1234", "tcp@10.0.0.2:1234", "tcp@10.0.0.3:1234", "tcp@10.0.0.4:1234"}},
+		{"3 ports", []string{"tcp@127.0.0.1:40001", "tcp@127.0.0.1:40002", "tcp@127.0.0.1:40003"}},
+	}
+	const keys = 10000
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := xclient.NewConsistentHashSelector(0)
+			counts := make(map[string]int)
+			for i := 0; i < keys; i++ {
+				counts[route(t, s, serverInfos(tt.servers...), fmt.Sprintf("user-%d", i))]++
+			}
+			fair := keys / len(tt.servers)
+			for _, addr := range tt.servers {
+				if n := counts[addr]; n < fair*3/4 || n > fair*5/4 {
+					t.Fatalf("counts = %v, want about %d each", counts, fair)
+				}
+			}
+		})
+	}
+}
+
+func serverInfos(addrs ...string) []xclient.ServerInfo {
+	servers := make([]xclient.ServerInfo, len(addrs))
+	for i, addr := range addrs {
+		servers[i] = xclient.ServerInfo{Addr: addr}
+	}
+	return servers
+}
+
+// 按路由键选出的实例
+func route(t *testing.T, s xclient.Selector, servers []xclient.ServerInfo, key string) string {
+	t.Helper()
+	addr, err := s.Select(xclient.WithRoutingKey(context.Background(), key), servers, xclient.CallInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr
+}
+
+// 实例增减时只有约1/N的键改变映射，且只在变化的实例与其余实例之间移动
+func TestConsistentHashRemapping(t *testing.T) {
+	const keys = 10000
+	base := []string{"tcp@10.0.0.1:1234", "tcp@10.0.0.2:1234", "tcp@10.0.0.3:1234", "tcp@10.0.0.4:1234"}
+	tests := []struct {
+		name    string
+		after   []string
+		changed string //新增或移除的实例
+		ratio   float64
+	}{
+		{"add", append(base[:4:4], "tcp@10.0.0.5:1234"), "tcp@10.0.0.5:1234", 1.0 / 5},
+		{"remove", base[1:], base[0], 1.0 / 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := xclient.NewConsistentHashSelector(0)
+			before := make([]string, keys)
+			for i := range before {
+				before[i] = route(t, s, serverInfos(base...), fmt.Sprintf("user-%d", i))
+			}
+			moved := 0
+			for i := range before {
+				addr := route(t, s, serverInfos(tt.after...), fmt.Sprintf("user-%d", i))
+				if addr == before[i] {
+					continue
+				}
+				moved++
+				if addr != tt.changed && before[i] != tt.changed {
+					t.Fatalf("key %d moved from %s to %s, neither is %s", i, before[i], addr, tt.changed)
+				}
+			}
+			if got := float64(moved) / keys; got < tt.ratio/2 || got > tt.ratio*1.5 {
+				t.Fatalf("%.1f%% of keys moved, want about %.1f%%", got*100, tt.ratio*100)
+			}
+		})
+	}
+}
+
+type userArgs struct{ ID string }
+
+func (a userArgs) RoutingKey() string { return a.ID }
+
+func TestConsistentHashRoutingKey(t *testing.T) {
+	s := xclient.NewConsistentHashSelector(0)
+	servers := serverInfos("a", "b", "c", "d")
+	//同一个键总是落在同一实例，服务列表顺序不影响结果
+	want := route(t, s, servers, "order-42")
+	reversed := serverInfos("d", "c", "b", "a")
+	for i := 0; i < 10; i++ {
+		if got := route(t, s, reversed, "order-42"); got != want {
+			t.Fatalf("key routed to %s, then %s", want, got)
+		}
+	}
+	//参数实现RoutingKeyer时按参数路由，ctx中的路由键优先
+	byArgs := pick(t, s, servers, xclient.CallInfo{Args: userArgs{ID: "order-42"}}, 10)
+	if byArgs[want] != 10 {
+		t.Fatalf("args routing = %v, want all on %s", byArgs, want)
+	}
+	other := "order-1"
+	for i := 2; route(t, s, servers, other) == want; i++ {
+		other = fmt.Sprintf("order-%d", i)
+	}
+	addr, _ := s.Select(xclient.WithRoutingKey(context.Background(), other), servers, xclient.CallInfo{Args: userArgs{ID: "order-42"}})
+	if addr == want {
+		t.Fatalf("ctx routing key did not take priority over args")
+	}
+	//没有路由键时随机选择
+	if counts := pick(t, s, servers, xclient.CallInfo{}, 400); len(counts) != len(servers) {
+		t.Fatalf("without routing key = %v, want every instance chosen", counts)
+	}
+}
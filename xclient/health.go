@@ -0,0 +1,188 @@
+package xclient
+
+import (
+	GeeRPC "codec"
+	"codec/client"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// 主动健康检查策略，定期调用每个实例的Health.Check
+type HealthCheckPolicy struct {
+	Interval time.Duration //检查间隔
+	Timeout  time.Duration //单次检查超时
+	Service  string        //检查的服务名，为空表示整个服务端
+}
+
+// 默认健康检查策略
+var DefaultHealthCheckPolicy = &HealthCheckPolicy{
+	Interval: time.Second * 5,
+	Timeout:  time.Second,
+}
+
+type healthChecker struct {
+	p      *HealthCheckPolicy
+	opt    *GeeRPC.Option //探测连接的选项，连接超时不超过检查超时
+	mu     sync.Mutex
+	status map[string]GeeRPC.ServingStatus //地址 -> 最近一次检查的结果
+	conns  map[string]*client.Client       //探测专用连接，不经过连接池，只由检查协程使用
+	done   chan struct{}
+}
+
+// 开启主动健康检查，nil表示关闭；检查结果不是Serving的实例不参与选择
+// Interval与Timeout为0时取DefaultHealthCheckPolicy中的值
+func (xc *XClient) SetHealthCheck(p *HealthCheckPolicy) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	if xc.health != nil {
+		close(xc.health.done)
+		xc.health = nil
+	}
+	if p == nil {
+		return
+	}
+	//未设置的字段取默认值，复制一份避免修改调用方的策略
+	policy := *p
+	if policy.Interval <= 0 {
+		policy.Interval = DefaultHealthCheckPolicy.Interval
+	}
+	if policy.Timeout <= 0 {
+		policy.Timeout = DefaultHealthCheckPolicy.Timeout
+	}
+	p = &policy
+	opt := *GeeRPC.DefaultOption
+	if xc.opt != nil {
+		opt = *xc.opt
+	}
+	if p.Timeout > 0 && (opt.ConnectTimeout <= 0 || p.Timeout < opt.ConnectTimeout) {
+		opt.ConnectTimeout = p.Timeout
+	}
+	h := &healthChecker{
+		p:      p,
+		opt:    &opt,
+		status: make(map[string]GeeRPC.ServingStatus),
+		conns:  make(map[string]*client.Client),
+		done:   make(chan struct{}),
+	}
+	xc.health = h
+	go xc.runHealthCheck(h)
+}
+
+func (xc *XClient) getHealth() *healthChecker {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	return xc.health
+}
+
+// 实例最近一次健康检查的结果，未检查过时为StatusUnknown
+func (xc *XClient) ServingStatus(rpcAddr string) GeeRPC.ServingStatus {
+	h := xc.getHealth()
+	if h == nil {
+		return GeeRPC.StatusUnknown
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.status[rpcAddr]
+}
+
+func (xc *XClient) runHealthCheck(h *healthChecker) {
+	ticker := time.NewTicker(h.p.Interval)
+	defer ticker.Stop()
+	defer h.closeConns()
+	for {
+		xc.checkAll(h)
+		select {
+		case <-h.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 并发检查所有实例，并清理已不在服务列表中的实例
+func (xc *XClient) checkAll(h *healthChecker) {
+	servers, err := xc.d.GetAll()
+	if err != nil {
+		return
+	}
+	status := make(map[string]GeeRPC.ServingStatus, len(servers))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	conns := make(map[string]*client.Client, len(servers))
+	for _, rpcAddr := range servers {
+		wg.Add(1)
+		go func(rpcAddr string) {
+			defer wg.Done()
+			c, s := h.check(rpcAddr, h.conns[rpcAddr])
+			mu.Lock()
+			status[rpcAddr] = s
+			if c != nil {
+				conns[rpcAddr] = c
+			}
+			mu.Unlock()
+		}(rpcAddr)
+	}
+	wg.Wait()
+	for rpcAddr, c := range h.conns {
+		if conns[rpcAddr] != c {
+			_ = c.Close()
+		}
+	}
+	h.conns = conns
+	h.mu.Lock()
+	h.status = status
+	h.mu.Unlock()
+}
+
+func (h *healthChecker) closeConns() {
+	for rpcAddr, c := range h.conns {
+		_ = c.Close()
+		delete(h.conns, rpcAddr)
+	}
+}
+
+// 通过探测专用连接检查单个实例，返回之后继续使用的连接
+// 连接失败或超时视为NotServing并丢弃连接，不支持健康检查的服务端视为Serving
+func (h *healthChecker) check(rpcAddr string, c *client.Client) (*client.Client, GeeRPC.ServingStatus) {
+	p := h.p
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+	if c == nil || !c.IsAvailable() {
+		var err error
+		if c, err = client.XDial(rpcAddr, h.opt); err != nil {
+			return nil, GeeRPC.NotServing
+		}
+	}
+	var resp GeeRPC.HealthCheckResponse
+	err := c.Call(ctx, GeeRPC.HealthServiceMethod, GeeRPC.HealthCheckRequest{Service: p.Service}, &resp)
+	switch {
+	case err == nil:
+		return c, resp.Status
+	case errors.Is(err, GeeRPC.ErrNotFound):
+		if p.Service == "" {
+			return c, GeeRPC.Serving
+		}
+		return c, GeeRPC.NotServing
+	default:
+		_ = c.Close()
+		return nil, GeeRPC.NotServing
+	}
+}
+
+// 过滤掉健康检查未通过的实例，全部未通过时返回原列表
+func (h *healthChecker) filter(servers []string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	available := make([]string, 0, len(servers))
+	for _, s := range servers {
+		if st, ok := h.status[s]; !ok || st == GeeRPC.Serving {
+			available = append(available, s)
+		}
+	}
+	if len(available) == 0 {
+		return servers
+	}
+	return available
+}
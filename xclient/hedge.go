@@ -0,0 +1,189 @@
+package xclient
+
+import (
+	GeeRPC "codec"
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 对冲策略，第一个请求在延迟内未返回时向另一个实例再发一次
+type HedgePolicy struct {
+	Delay      time.Duration //固定的对冲延迟
+	Percentile float64       //按方法观测到的延迟分位数确定延迟，如0.95；样本不足时使用Delay
+}
+
+// 分位数至少需要的样本数
+const minLatencySamples = 20
+
+// 保存最近的延迟样本
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+const latencyWindowSize = 256
+
+func (w *latencyWindow) add(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.samples) < latencyWindowSize {
+		w.samples = append(w.samples, d)
+		return
+	}
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % latencyWindowSize
+}
+
+// 计算分位数，样本不足时返回false
+func (w *latencyWindow) percentile(p float64) (time.Duration, bool) {
+	w.mu.Lock()
+	sorted := make([]time.Duration, len(w.samples))
+	copy(sorted, w.samples)
+	w.mu.Unlock()
+	if len(sorted) < minLatencySamples {
+		return 0, false
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(p * float64(len(sorted)))
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i], true
+}
+
+// 设置对冲策略
+func (xc *XClient) SetHedgePolicy(p *HedgePolicy) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	xc.hedge = p
+}
+
+// 记录成功调用的延迟
+func (xc *XClient) observe(serviceMethod string, d time.Duration) {
+	v, _ := xc.latencies.LoadOrStore(serviceMethod, &latencyWindow{})
+	v.(*latencyWindow).add(d)
+}
+
+// 当前方法的对冲延迟
+func (xc *XClient) hedgeDelay(serviceMethod string) time.Duration {
+	xc.mu.Lock()
+	p := xc.hedge
+	xc.mu.Unlock()
+	if p == nil {
+		return 0
+	}
+	if p.Percentile > 0 {
+		if v, ok := xc.latencies.Load(serviceMethod); ok {
+			if d, ok := v.(*latencyWindow).percentile(p.Percentile); ok {
+				return d
+			}
+		}
+	}
+	return p.Delay
+}
+
+// 选择与已用实例不同的地址
+func (xc *XClient) pickOther(ctx context.Context, serviceMethod string, args interface{}, used string) (string, bool) {
+	for i := 0; i < 3; i++ {
+		if addr, err := xc.selectServer(ctx, serviceMethod, args); err == nil && addr != used {
+			return addr, true
+		}
+	}
+	servers, err := xc.d.GetAll()
+	if err != nil {
+		return "", false
+	}
+	for _, s := range servers {
+		if s != used {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+type hedgeResult struct {
+	reply interface{}
+	err   error
+}
+
+// 对冲调用，只适用于幂等方法：第一个请求超过对冲延迟未返回或以可重试的错误失败时，
+// 向另一个实例再发一次，取先成功的结果并取消另一个；不可重试的错误（如参数错误）直接返回
+func (xc *XClient) HedgedCall(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	rpcAddr, err := xc.selectServer(ctx, serviceMethod, args)
+	if err != nil {
+		return err
+	}
+	p, _ := xc.retryFor(serviceMethod)
+	delay := xc.hedgeDelay(serviceMethod)
+	if delay <= 0 {
+		return xc.call(rpcAddr, ctx, serviceMethod, args, reply)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan hedgeResult, 2)
+	launch := func(addr string) {
+		var clonedReply interface{}
+		if reply != nil {
+			clonedReply = reflect.New(reflect.ValueOf(reply).Elem().Type()).Interface()
+		}
+		go func() {
+			err := xc.call(addr, ctx, serviceMethod, args, clonedReply)
+			results <- hedgeResult{reply: clonedReply, err: err}
+		}()
+	}
+	launch(rpcAddr)
+	pending, hedged := 1, false
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var firstErr error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				if reply != nil {
+					reflect.ValueOf(reply).Elem().Set(reflect.ValueOf(r.reply).Elem())
+				}
+				return nil
+			}
+			if !p.retryable(r.err, true) {
+				return r.err
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if hedged {
+				continue
+			}
+		case <-ctx.Done():
+			return ctxError("rpc xclient: 对冲调用超时 ", ctx.Err())
+		}
+		//超过延迟或第一个请求已失败，发出对冲请求
+		if !hedged {
+			hedged = true
+			if addr, ok := xc.pickOther(ctx, serviceMethod, args, rpcAddr); ok {
+				launch(addr)
+				pending++
+			}
+		}
+	}
+	if firstErr == nil {
+		firstErr = errors.New("rpc xclient: hedged call failed")
+	}
+	return firstErr
+}
+
+// 把ctx的错误转换为带错误码的错误，与Client.Call一致
+func ctxError(msg string, err error) error {
+	code := GeeRPC.CodeCanceled
+	if errors.Is(err, context.DeadlineExceeded) {
+		code = GeeRPC.CodeTimeout
+	}
+	return GeeRPC.Errorf(code, "%s%s", msg, err)
+}
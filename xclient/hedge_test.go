@@ -0,0 +1,82 @@
+package xclient_test
+
+import (
+	GeeRPC "codec"
+	"codec/xclient"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgedCallErrors(t *testing.T) {
+	xc := newXClient(t, startServers(t, 2))
+	xc.SetHedgePolicy(&xclient.HedgePolicy{Delay: 30 * time.Millisecond})
+	tests := []struct {
+		name    string
+		method  string
+		args    interface{}
+		timeout time.Duration
+		is      error
+		calls   int64 //Foo.Div应被调用的次数
+	}{
+		{"timeout", "Foo.Sleep", 500 * time.Millisecond, 60 * time.Millisecond, GeeRPC.ErrTimeout, 0},
+		{"non-retryable error is not hedged", "Foo.Div", [2]int{1, 0}, time.Second, GeeRPC.ErrInvalidArgument, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt64(&divCalls, 0)
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			var reply int
+			err := xc.HedgedCall(ctx, tt.method, tt.args, &reply)
+			if !errors.Is(err, tt.is) {
+				t.Fatalf("err = %v, want %v", err, tt.is)
+			}
+			//等待超过对冲延迟，确认没有发出对冲请求
+			time.Sleep(60 * time.Millisecond)
+			if n := atomic.LoadInt64(&divCalls); n != tt.calls {
+				t.Fatalf("Foo.Div called %d times, want %d", n, tt.calls)
+			}
+		})
+	}
+}
+
+func TestHedgedCall(t *testing.T) {
+	fast := startServer(t, Foo{})
+	slow := startServer(t, Foo{delay: 2 * time.Second})
+	tests := []struct {
+		name   string
+		first  string //第一个请求发往的实例
+		policy *xclient.HedgePolicy
+		warmup int //发起对冲前先在快实例上调用的次数，用于积累延迟样本
+		within time.Duration
+	}{
+		{"slow first instance", slow, &xclient.HedgePolicy{Delay: 20 * time.Millisecond}, 0, time.Second},
+		{"failed first instance", deadAddr(t), &xclient.HedgePolicy{Delay: 5 * time.Second}, 0, time.Second},
+		{"percentile delay", slow, &xclient.HedgePolicy{Delay: 5 * time.Second, Percentile: 0.9}, 30, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xc := newXClient(t, []string{fast, tt.first})
+			xc.SetSelector(firstSelector{})
+			for i := 0; i < tt.warmup; i++ {
+				var reply int
+				if err := xc.Call(context.Background(), "Foo.Sum", [2]int{1, 2}, &reply); err != nil {
+					t.Fatal(err)
+				}
+			}
+			xc.SetHedgePolicy(tt.policy)
+			xc.SetSelector(lastSelector{})
+			start := time.Now()
+			var reply int
+			if err := xc.HedgedCall(context.Background(), "Foo.Sum", [2]int{1, 2}, &reply); err != nil || reply != 3 {
+				t.Fatalf("reply = %d, err = %v; want 3", reply, err)
+			}
+			if elapsed := time.Since(start); elapsed > tt.within {
+				t.Fatalf("hedged call took %s, want the hedge to answer within %s", elapsed, tt.within)
+			}
+		})
+	}
+}
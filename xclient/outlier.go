@@ -0,0 +1,225 @@
+package xclient
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// 异常实例检测策略，被动统计调用结果，把异常实例暂时移出选择范围
+type OutlierPolicy struct {
+	ConsecutiveErrors  int           //连续失败次数达到该值时驱逐，不大于0表示不按失败驱逐
+	LatencyFactor      float64       //平均延迟超过所有实例中位数的倍数时驱逐，不大于0表示不按延迟驱逐
+	Interval           time.Duration //按延迟检测的间隔
+	BaseEjectionTime   time.Duration //第一次驱逐的时长，之后每次翻倍
+	MaxEjectionTime    time.Duration //驱逐时长上限
+	MaxEjectionPercent int           //最多同时驱逐的实例百分比
+}
+
+// 默认异常检测策略
+var DefaultOutlierPolicy = &OutlierPolicy{
+	ConsecutiveErrors:  5,
+	LatencyFactor:      3,
+	Interval:           time.Second * 10,
+	BaseEjectionTime:   time.Second * 30,
+	MaxEjectionTime:    time.Minute * 5,
+	MaxEjectionPercent: 50,
+}
+
+const (
+	minOutlierSamples = 10 //按延迟检测时实例至少需要的样本数
+	minOutlierHosts   = 3  //按延迟检测时至少需要的有效实例数，实例太少时中位数没有意义
+)
+
+// 实例的驱逐状态
+type OutlierState struct {
+	Ejected           bool
+	EjectedUntil      time.Time
+	Ejections         int           //驱逐次数，决定下一次驱逐时长，未被驱逐时每个检测间隔减一
+	ConsecutiveErrors int           //当前连续失败次数
+	Latency           time.Duration //成功调用的平均延迟
+}
+
+type outlierHost struct {
+	ejectedUntil time.Time
+	ejections    int
+	consecutive  int
+	latency      float64 //成功调用的平均延迟，纳秒
+	samples      int
+}
+
+type outlierDetector struct {
+	mu        sync.Mutex
+	p         *OutlierPolicy
+	hosts     map[string]*outlierHost
+	servers   int //最近一次选择时的实例数，用于计算驱逐比例
+	lastSweep time.Time
+}
+
+func newOutlierDetector(p *OutlierPolicy) *outlierDetector {
+	return &outlierDetector{p: p, hosts: make(map[string]*outlierHost), lastSweep: time.Now()}
+}
+
+func (o *outlierDetector) host(addr string) *outlierHost {
+	h, ok := o.hosts[addr]
+	if !ok {
+		h = &outlierHost{}
+		o.hosts[addr] = h
+	}
+	return h
+}
+
+// 当前被驱逐的实例数
+func (o *outlierDetector) ejectedCount(now time.Time) int {
+	n := 0
+	for _, h := range o.hosts {
+		if now.Before(h.ejectedUntil) {
+			n++
+		}
+	}
+	return n
+}
+
+// 驱逐实例，超过最大驱逐比例时不驱逐
+func (o *outlierDetector) eject(h *outlierHost, now time.Time) {
+	if now.Before(h.ejectedUntil) {
+		return
+	}
+	if (o.ejectedCount(now)+1)*100 > o.p.MaxEjectionPercent*o.servers {
+		return
+	}
+	d := o.p.BaseEjectionTime << uint(h.ejections)
+	if d <= 0 || (o.p.MaxEjectionTime > 0 && d > o.p.MaxEjectionTime) {
+		d = o.p.MaxEjectionTime
+	}
+	h.ejectedUntil = now.Add(d)
+	h.ejections++
+	h.consecutive = 0
+	//驱逐期间没有新样本，恢复后重新统计延迟
+	h.latency, h.samples = 0, 0
+}
+
+// 记录调用结果
+func (o *outlierDetector) record(addr string, d time.Duration, failed bool, now time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	h := o.host(addr)
+	if failed {
+		h.consecutive++
+		if o.p.ConsecutiveErrors > 0 && h.consecutive >= o.p.ConsecutiveErrors {
+			o.eject(h, now)
+		}
+		return
+	}
+	h.consecutive = 0
+	if h.samples == 0 {
+		h.latency = float64(d)
+	} else {
+		h.latency += ewmaAlpha * (float64(d) - h.latency)
+	}
+	h.samples++
+}
+
+// 按延迟检测异常实例，平均延迟超过中位数LatencyFactor倍的实例被驱逐
+func (o *outlierDetector) sweep(now time.Time) {
+	o.lastSweep = now
+	for _, h := range o.hosts {
+		//未被驱逐的实例逐渐降低驱逐次数
+		if !now.Before(h.ejectedUntil) && h.ejections > 0 && now.Sub(h.ejectedUntil) >= o.p.Interval {
+			h.ejections--
+		}
+	}
+	if o.p.LatencyFactor <= 0 {
+		return
+	}
+	latencies := make([]float64, 0, len(o.hosts))
+	for _, h := range o.hosts {
+		if !now.Before(h.ejectedUntil) && h.samples >= minOutlierSamples {
+			latencies = append(latencies, h.latency)
+		}
+	}
+	if len(latencies) < minOutlierHosts {
+		return
+	}
+	sort.Float64s(latencies)
+	median := latencies[len(latencies)/2]
+	for _, h := range o.hosts {
+		if !now.Before(h.ejectedUntil) && h.samples >= minOutlierSamples && h.latency > o.p.LatencyFactor*median {
+			o.eject(h, now)
+		}
+	}
+}
+
+// 过滤掉被驱逐的实例，全部被驱逐时返回原列表
+func (o *outlierDetector) filter(servers []string) []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	o.servers = len(servers)
+	//只保留仍在列表中的实例
+	known := make(map[string]bool, len(servers))
+	for _, s := range servers {
+		known[s] = true
+	}
+	for addr := range o.hosts {
+		if !known[addr] {
+			delete(o.hosts, addr)
+		}
+	}
+	if now.Sub(o.lastSweep) >= o.p.Interval {
+		o.sweep(now)
+	}
+	available := make([]string, 0, len(servers))
+	for _, s := range servers {
+		if h, ok := o.hosts[s]; !ok || !now.Before(h.ejectedUntil) {
+			available = append(available, s)
+		}
+	}
+	if len(available) == 0 {
+		return servers
+	}
+	return available
+}
+
+func (o *outlierDetector) states() map[string]OutlierState {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := time.Now()
+	states := make(map[string]OutlierState, len(o.hosts))
+	for addr, h := range o.hosts {
+		states[addr] = OutlierState{
+			Ejected:           now.Before(h.ejectedUntil),
+			EjectedUntil:      h.ejectedUntil,
+			Ejections:         h.ejections,
+			ConsecutiveErrors: h.consecutive,
+			Latency:           time.Duration(h.latency),
+		}
+	}
+	return states
+}
+
+// 设置异常实例检测策略，nil表示不检测
+func (xc *XClient) SetOutlierPolicy(p *OutlierPolicy) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	if p == nil {
+		xc.outliers = nil
+		return
+	}
+	xc.outliers = newOutlierDetector(p)
+}
+
+func (xc *XClient) getOutliers() *outlierDetector {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	return xc.outliers
+}
+
+// 各实例的驱逐状态，用于排查问题
+func (xc *XClient) OutlierStates() map[string]OutlierState {
+	o := xc.getOutliers()
+	if o == nil {
+		return nil
+	}
+	return o.states()
+}
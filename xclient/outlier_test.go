@@ -0,0 +1,122 @@
+package xclient_test
+
+import (
+	"codec/xclient"
+	"context"
+	"testing"
+	"time"
+)
+
+// 调用n次，返回失败次数
+func callN(xc *xclient.XClient, method string, args interface{}, n int) int {
+	failed := 0
+	for i := 0; i < n; i++ {
+		var reply int
+		if err := xc.Call(context.Background(), method, args, &reply); err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+func ejected(xc *xclient.XClient, addrs ...string) int {
+	n := 0
+	states := xc.OutlierStates()
+	for _, addr := range addrs {
+		if states[addr].Ejected {
+			n++
+		}
+	}
+	return n
+}
+
+// 连续失败的实例被驱逐，同时驱逐的实例数不超过MaxEjectionPercent
+func TestOutlierEjection(t *testing.T) {
+	live := startServers(t, 2)
+	dead := []string{deadAddr(t), deadAddr(t)}
+	tests := []struct {
+		name    string
+		percent int
+		ejected int
+	}{
+		{"eject failing instances", 50, 2},
+		{"max ejection percent", 25, 1},
+		{"no ejection allowed", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xc := newXClient(t, append(live[:2:2], dead...))
+			xc.SetFailMode(xclient.Failfast)
+			xc.SetOutlierPolicy(&xclient.OutlierPolicy{
+				ConsecutiveErrors:  2,
+				Interval:           time.Minute,
+				BaseEjectionTime:   time.Minute,
+				MaxEjectionPercent: tt.percent,
+			})
+			callN(xc, "Foo.Sum", [2]int{1, 2}, 16)
+			if n := ejected(xc, dead...); n != tt.ejected {
+				t.Fatalf("%d failing instances ejected, want %d: %+v", n, tt.ejected, xc.OutlierStates())
+			}
+			if n := ejected(xc, live...); n != 0 {
+				t.Fatalf("healthy instances ejected: %+v", xc.OutlierStates())
+			}
+			//被驱逐的实例不再被选中，只有未被驱逐的失效实例还会导致失败
+			if failed := callN(xc, "Foo.Sum", [2]int{1, 2}, 12); (failed > 0) != (tt.ejected < len(dead)) {
+				t.Fatalf("%d calls failed after ejecting %d instances", failed, tt.ejected)
+			}
+		})
+	}
+}
+
+func TestOutlierRecovery(t *testing.T) {
+	addrs := startServers(t, 3)
+	dead := deadAddr(t)
+	xc := newXClient(t, append(addrs, dead))
+	xc.SetFailMode(xclient.Failfast)
+	xc.SetOutlierPolicy(&xclient.OutlierPolicy{
+		ConsecutiveErrors:  1,
+		Interval:           time.Minute,
+		BaseEjectionTime:   50 * time.Millisecond,
+		MaxEjectionPercent: 50,
+	})
+	callN(xc, "Foo.Sum", [2]int{1, 2}, 4)
+	if ejected(xc, dead) != 1 {
+		t.Fatalf("dead instance not ejected: %+v", xc.OutlierStates())
+	}
+	//驱逐到期后实例重新参与选择，再次失败时驱逐时长翻倍
+	time.Sleep(60 * time.Millisecond)
+	if ejected(xc, dead) != 0 {
+		t.Fatalf("ejection did not expire: %+v", xc.OutlierStates())
+	}
+	callN(xc, "Foo.Sum", [2]int{1, 2}, 4)
+	s := xc.OutlierStates()[dead]
+	if !s.Ejected || s.Ejections != 2 || time.Until(s.EjectedUntil) < 60*time.Millisecond {
+		t.Fatalf("state after second ejection = %+v, want a doubled ejection", s)
+	}
+	//业务错误不计入
+	callN(xc, "Foo.Div", [2]int{1, 0}, 12)
+	for _, addr := range addrs {
+		if s := xc.OutlierStates()[addr]; s.Ejected || s.ConsecutiveErrors != 0 {
+			t.Fatalf("application errors counted against %s: %+v", addr, s)
+		}
+	}
+}
+
+// 平均延迟远高于其他实例的实例被驱逐
+func TestOutlierLatencyEjection(t *testing.T) {
+	fast := startServers(t, 3)
+	slow := startServer(t, Foo{delay: 20 * time.Millisecond})
+	xc := newXClient(t, append(fast, slow))
+	xc.SetOutlierPolicy(&xclient.OutlierPolicy{
+		LatencyFactor:      3,
+		Interval:           time.Millisecond,
+		BaseEjectionTime:   time.Minute,
+		MaxEjectionPercent: 25,
+	})
+	if failed := callN(xc, "Foo.Sum", [2]int{1, 2}, 60); failed > 0 {
+		t.Fatalf("%d calls failed", failed)
+	}
+	if ejected(xc, slow) != 1 || ejected(xc, fast...) != 0 {
+		t.Fatalf("states = %+v, want only the slow instance ejected", xc.OutlierStates())
+	}
+}
@@ -0,0 +1,195 @@
+package xclient
+
+import (
+	"codec/client"
+	"sync"
+	"time"
+)
+
+// 连接池策略，按实例维护多条连接
+type PoolPolicy struct {
+	MinConns    int           //每个实例至少保持的连接数
+	MaxConns    int           //每个实例最多的连接数，所有连接都有未完成请求时才新建连接
+	IdleTimeout time.Duration //超过MinConns的连接空闲该时间后关闭，0表示不关闭
+}
+
+// 未设置连接池策略时每个实例一条连接
+var defaultPoolPolicy = &PoolPolicy{MinConns: 1, MaxConns: 1}
+
+type pooledConn struct {
+	c        *client.Client
+	lastUsed time.Time
+}
+
+// 进行中的拨号，结束后关闭done，err为拨号结果
+type poolDial struct {
+	done chan struct{}
+	err  error
+}
+
+// 单个实例的连接池，拨号在锁外进行，同一时刻每个实例最多一个拨号
+type connPool struct {
+	mu      sync.Mutex
+	conns   []*pooledConn
+	dialing *poolDial //进行中的拨号，nil表示没有
+	closed  bool
+}
+
+// 设置连接池策略，nil表示每个实例一条连接
+func (xc *XClient) SetPoolPolicy(p *PoolPolicy) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	xc.pool = p
+}
+
+// 清理不可用与空闲超时的连接，选出未完成请求最少的连接
+// 繁忙且未达上限时在后台新建连接，没有可用连接时等待拨号完成
+func (p *connPool) get(policy *PoolPolicy, dial func() (*client.Client, error)) (*client.Client, error) {
+	if policy == nil {
+		policy = defaultPoolPolicy
+	}
+	maxConns := policy.MaxConns
+	if maxConns < policy.MinConns {
+		maxConns = policy.MinConns
+	}
+	if maxConns < 1 {
+		maxConns = 1
+	}
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, client.ErrShutdown
+		}
+		best, least := p.prune(policy, time.Now())
+		grow := best == nil || len(p.conns) < policy.MinConns || (least > 0 && len(p.conns) < maxConns)
+		if best != nil && (!grow || p.dialing != nil) {
+			best.lastUsed = time.Now()
+			p.mu.Unlock()
+			return best.c, nil
+		}
+		if d := p.dialing; d != nil {
+			//没有可用连接，等待进行中的拨号
+			p.mu.Unlock()
+			<-d.done
+			if d.err != nil {
+				return nil, d.err
+			}
+			continue
+		}
+		d := &poolDial{done: make(chan struct{})}
+		p.dialing = d
+		if best != nil {
+			//已有可用连接，后台扩容
+			best.lastUsed = time.Now()
+			p.mu.Unlock()
+			go func() { _, _ = p.dial(d, dial) }()
+			return best.c, nil
+		}
+		p.mu.Unlock()
+		return p.dial(d, dial)
+	}
+}
+
+// 在锁外拨号，成功后加入连接池
+func (p *connPool) dial(d *poolDial, dial func() (*client.Client, error)) (*client.Client, error) {
+	c, err := dial()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == nil && p.closed {
+		_ = c.Close()
+		c, err = nil, client.ErrShutdown
+	}
+	if err == nil {
+		p.conns = append(p.conns, &pooledConn{c: c, lastUsed: time.Now()})
+	}
+	d.err = err
+	p.dialing = nil
+	close(d.done)
+	return c, err
+}
+
+// 移除不可用与空闲超时的连接，返回未完成请求最少的连接及其请求数，需持有p.mu
+func (p *connPool) prune(policy *PoolPolicy, now time.Time) (*pooledConn, int) {
+	conns := p.conns[:0]
+	for _, pc := range p.conns {
+		if !pc.c.IsAvailable() {
+			_ = pc.c.Close()
+			continue
+		}
+		conns = append(conns, pc)
+	}
+	//关闭超过MinConns的空闲连接
+	for i := 0; i < len(conns) && len(conns) > policy.MinConns; {
+		pc := conns[i]
+		if policy.IdleTimeout > 0 && pc.c.Pending() == 0 && now.Sub(pc.lastUsed) > policy.IdleTimeout {
+			_ = pc.c.Close()
+			conns = append(conns[:i], conns[i+1:]...)
+			continue
+		}
+		i++
+	}
+	p.conns = conns
+	var best *pooledConn
+	least := 0
+	for _, pc := range p.conns {
+		if n := pc.c.Pending(); best == nil || n < least {
+			best, least = pc, n
+		}
+	}
+	return best, least
+}
+
+// 所有连接上未完成的请求数
+func (p *connPool) pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, pc := range p.conns {
+		n += pc.c.Pending()
+	}
+	return n
+}
+
+func (p *connPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for _, pc := range p.conns {
+		_ = pc.c.Close()
+	}
+	p.conns = nil
+}
+
+// 合并连接池中各连接的运行状态
+func (p *connPool) stats() client.Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := client.Stats{State: client.StateShutdown}
+	for _, pc := range p.conns {
+		cs := pc.c.Stats()
+		if cs.State == client.StateReady {
+			s.State = client.StateReady
+		}
+		s.Pending += cs.Pending
+		if cs.OldestPending > s.OldestPending {
+			s.OldestPending, s.OldestPendingMethod = cs.OldestPending, cs.OldestPendingMethod
+		}
+		s.BytesSent += cs.BytesSent
+		s.BytesReceived += cs.BytesReceived
+		s.Completed += cs.Completed
+		s.Failed += cs.Failed
+	}
+	return s
+}
+
+// 各实例连接的运行状态，同一实例的多条连接合并统计
+func (xc *XClient) Stats() map[string]client.Stats {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	stats := make(map[string]client.Stats, len(xc.clients))
+	for addr, pool := range xc.clients {
+		stats[addr] = pool.stats()
+	}
+	return stats
+}
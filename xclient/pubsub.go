@@ -0,0 +1,32 @@
+package xclient
+
+import (
+	GeeRPC "codec"
+	"context"
+	"errors"
+)
+
+// 把事件发布到服务发现中的所有实例，返回收到事件的订阅者总数
+// 部分实例失败时仍返回其余实例的投递数，error为其中一个失败原因
+func (xc *XClient) Publish(ctx context.Context, topic string, data []byte) (int, error) {
+	var delivered int
+	results, err := xc.BroadcastAll(ctx, GeeRPC.PubSubPublish, GeeRPC.Event{Topic: topic, Data: data}, &delivered)
+	if err != nil {
+		return 0, err
+	}
+	if len(results) == 0 {
+		return 0, errors.New("没有找到任何服务")
+	}
+	total := 0
+	var firstErr error
+	for _, r := range results {
+		if r.Err != nil {
+			if firstErr == nil {
+				firstErr = r.Err
+			}
+			continue
+		}
+		total += *r.Reply.(*int)
+	}
+	return total, firstErr
+}
@@ -0,0 +1,71 @@
+package xclient_test
+
+import (
+	GeeRPC "codec"
+	"codec/client"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// 启动注册了PubSub的服务端，并在该服务端上订阅topic
+func startSubscribed(t *testing.T, topic string) (string, *GeeRPC.Event, *client.Stream) {
+	t.Helper()
+	ps := GeeRPC.NewPubSub()
+	server := GeeRPC.NewServer()
+	if err := server.Register(ps); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go server.Accept(l)
+	c, err := client.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	ev := new(GeeRPC.Event)
+	st := c.Subscribe(context.Background(), topic, ev)
+	for deadline := time.Now().Add(5 * time.Second); ps.Subscribers(topic) == 0; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("subscription not registered")
+		}
+	}
+	return "tcp@" + l.Addr().String(), ev, st
+}
+
+// 发布扇出到所有实例，部分实例失败时仍返回其余实例的投递数
+func TestPublish(t *testing.T) {
+	addr1, ev1, st1 := startSubscribed(t, "orders")
+	addr2, ev2, st2 := startSubscribed(t, "orders")
+	tests := []struct {
+		name      string
+		servers   []string
+		delivered int
+		wantErr   bool
+	}{
+		{"all instances", []string{addr1, addr2}, 2, false},
+		{"one instance down", []string{addr1, addr2, deadAddr(t)}, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xc := newXClient(t, tt.servers)
+			n, err := xc.Publish(context.Background(), "orders", []byte(tt.name))
+			if n != tt.delivered || (err != nil) != tt.wantErr {
+				t.Fatalf("Publish = %d, %v; want %d, error %v", n, err, tt.delivered, tt.wantErr)
+			}
+			for i, sub := range []struct {
+				ev *GeeRPC.Event
+				st *client.Stream
+			}{{ev1, st1}, {ev2, st2}} {
+				if !sub.st.Next() || string(sub.ev.Data) != tt.name {
+					t.Fatalf("subscriber %d got %q, want %q (err %v)", i, sub.ev.Data, tt.name, sub.st.Err())
+				}
+			}
+		})
+	}
+}
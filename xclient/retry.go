@@ -0,0 +1,204 @@
+package xclient
+
+import (
+	GeeRPC "codec"
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// 失败处理模式，决定重试时选择哪个实例
+type FailMode int
+
+const (
+	Failover FailMode = iota //按服务列表顺序换下一个实例，直到成功或每个实例都尝试过
+	Failfast                 //只调用一次，失败直接返回
+	Failtry                  //在同一实例上重试
+)
+
+// 重试策略
+type RetryPolicy struct {
+	MaxAttempts     int           //Failtry模式下最多尝试次数，包含第一次；Failover模式下每个实例各尝试一次
+	BaseBackoff     time.Duration //第一次重试前的退避上限，之后每次翻倍
+	MaxBackoff      time.Duration //退避上限
+	RetryableCodes  []GeeRPC.Code //所有方法都重试的错误码，这类错误表示请求未被执行
+	IdempotentCodes []GeeRPC.Code //只有幂等方法才重试的错误码，请求可能已被执行
+}
+
+// 默认重试策略
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxAttempts:     3,
+	BaseBackoff:     time.Millisecond * 50,
+	MaxBackoff:      time.Second,
+	RetryableCodes:  []GeeRPC.Code{GeeRPC.CodeOverloaded, GeeRPC.CodeRateLimited},
+	IdempotentCodes: []GeeRPC.Code{GeeRPC.CodeTimeout, GeeRPC.CodeUnavailable, GeeRPC.CodeInternal},
+}
+
+// 连接实例失败，请求未发出，任何方法都可以重试
+type dialError struct {
+	addr string
+	err  error
+}
+
+func (e *dialError) Error() string {
+	return "rpc xclient: dial " + e.addr + ": " + e.err.Error()
+}
+
+func (e *dialError) Unwrap() error { return e.err }
+
+// 设置重试策略，nil表示使用DefaultRetryPolicy，不需要重试的方法使用Failfast模式
+func (xc *XClient) SetRetryPolicy(p *RetryPolicy) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	xc.retry = p
+}
+
+// 声明幂等方法，serviceMethod形如"Service.Method"
+func (xc *XClient) SetIdempotent(serviceMethods ...string) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	for _, m := range serviceMethods {
+		xc.idempotent[m] = true
+	}
+}
+
+func (xc *XClient) isIdempotent(serviceMethod string) bool {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	return xc.idempotent[serviceMethod]
+}
+
+// 设置默认的失败处理模式
+func (xc *XClient) SetFailMode(mode FailMode) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	xc.failMode = mode
+}
+
+// 为单个方法设置失败处理模式，优先于默认模式
+func (xc *XClient) SetMethodFailMode(serviceMethod string, mode FailMode) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	xc.methodModes[serviceMethod] = mode
+}
+
+// 方法使用的重试策略与失败处理模式
+func (xc *XClient) retryFor(serviceMethod string) (*RetryPolicy, FailMode) {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	mode, ok := xc.methodModes[serviceMethod]
+	if !ok {
+		mode = xc.failMode
+	}
+	if xc.retry == nil {
+		return DefaultRetryPolicy, mode
+	}
+	return xc.retry, mode
+}
+
+// 判断错误是否可以重试
+func (p *RetryPolicy) retryable(err error, idempotent bool) bool {
+	var de *dialError
+	if errors.As(err, &de) || errors.Is(err, ErrBreakerOpen) {
+		return true
+	}
+	var e *GeeRPC.Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	codes := p.RetryableCodes
+	if idempotent {
+		codes = append(codes[:len(codes):len(codes)], p.IdempotentCodes...)
+	}
+	for _, c := range codes {
+		if c == e.Code {
+			return true
+		}
+	}
+	return false
+}
+
+// 第attempt次重试前的退避时间，指数增长并全量抖动，服务端给出重试间隔时不短于该间隔
+func (p *RetryPolicy) backoff(attempt int, err error) time.Duration {
+	d := p.BaseBackoff << uint(attempt)
+	if d <= 0 || (p.MaxBackoff > 0 && d > p.MaxBackoff) {
+		d = p.MaxBackoff
+	}
+	if d > 0 {
+		d = time.Duration(rand.Int63n(int64(d)) + 1)
+	}
+	var e *GeeRPC.Error
+	if errors.As(err, &e) && e.RetryAfter > d {
+		d = e.RetryAfter
+	}
+	return d
+}
+
+func (xc *XClient) callWithRetry(p *RetryPolicy, mode FailMode, rpcAddr string, ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	idempotent := xc.isIdempotent(serviceMethod)
+	maxAttempts := p.MaxAttempts
+	if mode == Failover {
+		servers, err := xc.d.GetAll()
+		if err != nil {
+			return err
+		}
+		maxAttempts = len(servers)
+	}
+	tried := map[string]bool{}
+	var err error
+	for attempt := 0; ; attempt++ {
+		tried[rpcAddr] = true
+		err = xc.call(rpcAddr, ctx, serviceMethod, args, reply)
+		if err == nil || attempt+1 >= maxAttempts || !p.retryable(err, idempotent) {
+			return err
+		}
+		wait := p.backoff(attempt, err)
+		//剩余时间不够退避时直接返回
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= wait {
+			return err
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+		if mode == Failover {
+			next, e := xc.nextServer(rpcAddr, tried)
+			if e != nil {
+				return err
+			}
+			rpcAddr = next
+		}
+	}
+}
+
+// 按服务列表顺序取当前实例之后第一个可用且未尝试过的实例
+func (xc *XClient) nextServer(rpcAddr string, tried map[string]bool) (string, error) {
+	servers, err := xc.d.GetAll()
+	if err != nil {
+		return "", err
+	}
+	if available, err := xc.healthyServers(servers); err == nil {
+		servers = available
+	}
+	n := len(servers)
+	if n == 0 {
+		return "", errors.New("没有找到任何服务")
+	}
+	start := 0
+	for i, s := range servers {
+		if s == rpcAddr {
+			start = i + 1
+			break
+		}
+	}
+	for i := 0; i < n; i++ {
+		if s := servers[(start+i)%n]; !tried[s] {
+			return s, nil
+		}
+	}
+	return "", errors.New("所有可用实例都已尝试")
+}
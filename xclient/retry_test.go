@@ -0,0 +1,97 @@
+package xclient_test
+
+import (
+	GeeRPC "codec"
+	"codec/xclient"
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 只有请求未被执行的错误对所有方法重试，可能已执行的错误只对幂等方法重试
+func TestRetryClassification(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       GeeRPC.Code
+		idempotent bool
+		attempts   int64
+	}{
+		{"overloaded", GeeRPC.CodeOverloaded, false, 3},
+		{"rate limited", GeeRPC.CodeRateLimited, false, 3},
+		{"timeout, not idempotent", GeeRPC.CodeTimeout, false, 1},
+		{"timeout, idempotent", GeeRPC.CodeTimeout, true, 3},
+		{"unavailable, idempotent", GeeRPC.CodeUnavailable, true, 3},
+		{"invalid argument, idempotent", GeeRPC.CodeInvalidArgument, true, 1},
+		{"unknown", GeeRPC.CodeUnknown, true, 1},
+	}
+	addrs := startServers(t, 1)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xc := newXClient(t, addrs)
+			xc.SetFailMode(xclient.Failtry)
+			xc.SetRetryPolicy(&xclient.RetryPolicy{
+				MaxAttempts:     3,
+				BaseBackoff:     time.Millisecond,
+				MaxBackoff:      time.Millisecond,
+				RetryableCodes:  xclient.DefaultRetryPolicy.RetryableCodes,
+				IdempotentCodes: xclient.DefaultRetryPolicy.IdempotentCodes,
+			})
+			if tt.idempotent {
+				xc.SetIdempotent("Foo.Fail")
+			}
+			atomic.StoreInt64(&failCalls, 0)
+			var reply int
+			err := xc.Call(context.Background(), "Foo.Fail", FailArgs{Code: tt.code}, &reply)
+			if GeeRPC.CodeOf(err) != tt.code {
+				t.Fatalf("err = %v, want code %s", err, tt.code)
+			}
+			if n := atomic.LoadInt64(&failCalls); n != tt.attempts {
+				t.Fatalf("attempts = %d, want %d", n, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     *xclient.RetryPolicy
+		retryAfter time.Duration
+		timeout    time.Duration
+		attempts   int64
+		min, max   time.Duration //总耗时范围
+	}{
+		//抖动后的退避不超过上限：两次退避各不超过20ms
+		{"capped jitter", &xclient.RetryPolicy{MaxAttempts: 3, BaseBackoff: 20 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}, 0, time.Second, 3, 0, 200 * time.Millisecond},
+		//服务端给出的重试间隔优先于更短的退避
+		{"retry after", &xclient.RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond}, 50 * time.Millisecond, time.Second, 3, 100 * time.Millisecond, time.Second},
+		//剩余时间不够退避时不再重试
+		{"deadline", &xclient.RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Second, MaxBackoff: time.Second}, time.Second, 100 * time.Millisecond, 1, 0, 50 * time.Millisecond},
+	}
+	addrs := startServers(t, 1)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xc := newXClient(t, addrs)
+			xc.SetFailMode(xclient.Failtry)
+			tt.policy.RetryableCodes = []GeeRPC.Code{GeeRPC.CodeOverloaded}
+			xc.SetRetryPolicy(tt.policy)
+			atomic.StoreInt64(&failCalls, 0)
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			start := time.Now()
+			var reply int
+			err := xc.Call(ctx, "Foo.Fail", FailArgs{Code: GeeRPC.CodeOverloaded, RetryAfter: tt.retryAfter}, &reply)
+			elapsed := time.Since(start)
+			if !GeeRPC.IsRetryable(err) {
+				t.Fatalf("err = %v, want the last overloaded error", err)
+			}
+			if n := atomic.LoadInt64(&failCalls); n != tt.attempts {
+				t.Fatalf("attempts = %d, want %d", n, tt.attempts)
+			}
+			if elapsed < tt.min || elapsed > tt.max {
+				t.Fatalf("elapsed %s, want between %s and %s", elapsed, tt.min, tt.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package xclient
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 可供选择的实例
+type ServerInfo struct {
+	Addr     string
+	Metadata map[string]string //服务发现提供的metadata，如weight
+	Pending  int               //XClient到该实例连接上未完成的请求数，尚未建立连接时为0
+}
+
+// 本次调用的信息
+type CallInfo struct {
+	ServiceMethod string
+	Args          interface{}
+}
+
+// 实例选择器，XClient每次调用前通过它选出实例，调用结束后把结果反馈给它
+// 实现需要并发安全
+type Selector interface {
+	// 从servers中选出一个实例地址，servers不为空
+	Select(ctx context.Context, servers []ServerInfo, call CallInfo) (string, error)
+	// 调用结束后的反馈，d为调用耗时，调用方主动取消的调用不会反馈
+	Feedback(addr string, call CallInfo, d time.Duration, err error)
+}
+
+// 按选择策略创建内置选择器
+func NewSelector(mode SelectMode) Selector {
+	switch mode {
+	case RandomSelect:
+		return &randomSelector{}
+	case RoundRobinSelect:
+		return &roundRobinSelector{index: rand.Intn(math.MaxInt32 - 1)}
+	case WeightedRoundRobinSelect:
+		return &weightedRoundRobinSelector{current: make(map[string]int)}
+	case LeastPendingSelect:
+		return &leastPendingSelector{}
+	case PowerOfTwoSelect:
+		return &powerOfTwoSelector{}
+	case ConsistentHashSelect:
+		return NewConsistentHashSelector(defaultReplicas)
+	case LatencyAwareSelect:
+		return &latencySelector{}
+	default:
+		return &unsupportedSelector{}
+	}
+}
+
+// 不需要反馈的选择器嵌入该类型
+type noFeedback struct{}
+
+func (noFeedback) Feedback(string, CallInfo, time.Duration, error) {}
+
+type unsupportedSelector struct{ noFeedback }
+
+func (*unsupportedSelector) Select(context.Context, []ServerInfo, CallInfo) (string, error) {
+	return "", errors.New("不支持所选模式")
+}
+
+// 随机选择
+type randomSelector struct{ noFeedback }
+
+func (*randomSelector) Select(_ context.Context, servers []ServerInfo, _ CallInfo) (string, error) {
+	return servers[rand.Intn(len(servers))].Addr, nil
+}
+
+// 轮询
+type roundRobinSelector struct {
+	noFeedback
+	mu    sync.Mutex
+	index int
+}
+
+func (s *roundRobinSelector) Select(_ context.Context, servers []ServerInfo, _ CallInfo) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(servers)
+	addr := servers[s.index%n].Addr
+	s.index = (s.index + 1) % n
+	return addr, nil
+}
+
+// 平滑加权轮询：每轮所有实例加上自身权重，选出当前权重最大的实例并减去总权重
+type weightedRoundRobinSelector struct {
+	noFeedback
+	mu      sync.Mutex
+	current map[string]int //实例的当前权重
+}
+
+// 实例权重，未设置或不合法时为1
+func weight(server ServerInfo) int {
+	w, err := strconv.Atoi(server.Metadata["weight"])
+	if err != nil || w < 1 {
+		return 1
+	}
+	return w
+}
+
+func (s *weightedRoundRobinSelector) Select(_ context.Context, servers []ServerInfo, _ CallInfo) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	//只保留仍在列表中的实例
+	current := make(map[string]int, len(servers))
+	total, best := 0, ""
+	for _, server := range servers {
+		w := weight(server)
+		total += w
+		current[server.Addr] = s.current[server.Addr] + w
+		if best == "" || current[server.Addr] > current[best] {
+			best = server.Addr
+		}
+	}
+	current[best] -= total
+	s.current = current
+	return best, nil
+}
@@ -0,0 +1,193 @@
+package xclient_test
+
+import (
+	GeeRPC "codec"
+	"codec/xclient"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 选择n次，统计每个实例被选中的次数
+func pick(t *testing.T, s xclient.Selector, servers []xclient.ServerInfo, call xclient.CallInfo, n int) map[string]int {
+	t.Helper()
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		addr, err := s.Select(context.Background(), servers, call)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[addr]++
+	}
+	return counts
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights map[string]string //实例 -> metadata中的weight
+		picks   int
+		want    map[string]int
+	}{
+		{"3:1", map[string]string{"a": "3", "b": "1"}, 400, map[string]int{"a": 300, "b": 100}},
+		{"no weight", map[string]string{"a": "", "b": ""}, 10, map[string]int{"a": 5, "b": 5}},
+		{"invalid weight counts as 1", map[string]string{"a": "2", "b": "x", "c": "-3"}, 40, map[string]int{"a": 20, "b": 10, "c": 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := xclient.NewMultiserversDiscovery([]string{"a", "b", "c"}[:len(tt.weights)])
+			for addr, w := range tt.weights {
+				d.SetMetadata(addr, map[string]string{"weight": w})
+			}
+			counts := make(map[string]int)
+			for i := 0; i < tt.picks; i++ {
+				addr, err := d.Get(xclient.WeightedRoundRobinSelect)
+				if err != nil {
+					t.Fatal(err)
+				}
+				counts[addr]++
+			}
+			for addr, want := range tt.want {
+				if counts[addr] != want {
+					t.Fatalf("counts = %v, want %v", counts, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// 平滑加权：权重小的实例不会连续被选中，权重大的实例也不会集中在一起
+func TestWeightedRoundRobinIsSmooth(t *testing.T) {
+	s := xclient.NewSelector(xclient.WeightedRoundRobinSelect)
+	servers := []xclient.ServerInfo{
+		{Addr: "a", Metadata: map[string]string{"weight": "3"}},
+		{Addr: "b", Metadata: map[string]string{"weight": "1"}},
+	}
+	var seq string
+	for i := 0; i < 8; i++ {
+		addr, _ := s.Select(context.Background(), servers, xclient.CallInfo{})
+		seq += addr
+	}
+	if seq != "aabaaaba" {
+		t.Fatalf("sequence = %s, want aabaaaba", seq)
+	}
+}
+
+func TestPendingSelectors(t *testing.T) {
+	servers := []xclient.ServerInfo{{Addr: "idle", Pending: 0}, {Addr: "busy", Pending: 5}, {Addr: "busiest", Pending: 10}}
+	tests := []struct {
+		name  string
+		mode  xclient.SelectMode
+		never []string //不应被选中的实例
+		seen  []string //应被选中过的实例
+	}{
+		{"least pending", xclient.LeastPendingSelect, []string{"busy", "busiest"}, []string{"idle"}},
+		//两个随机实例中选较空闲的，最忙的实例永远不会胜出
+		{"power of two", xclient.PowerOfTwoSelect, []string{"busiest"}, []string{"idle", "busy"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counts := pick(t, xclient.NewSelector(tt.mode), servers, xclient.CallInfo{}, 1000)
+			for _, addr := range tt.never {
+				if counts[addr] > 0 {
+					t.Errorf("%s selected %d times: %v", addr, counts[addr], counts)
+				}
+			}
+			for _, addr := range tt.seen {
+				if counts[addr] == 0 {
+					t.Errorf("%s never selected: %v", addr, counts)
+				}
+			}
+		})
+	}
+}
+
+// 未完成请求数相同时随机选择，不会总选第一个
+func TestLeastPendingTies(t *testing.T) {
+	servers := []xclient.ServerInfo{{Addr: "a"}, {Addr: "b"}, {Addr: "c", Pending: 1}}
+	counts := pick(t, xclient.NewSelector(xclient.LeastPendingSelect), servers, xclient.CallInfo{}, 1000)
+	if counts["a"] < 300 || counts["b"] < 300 || counts["c"] != 0 {
+		t.Fatalf("counts = %v, want a and b chosen evenly", counts)
+	}
+}
+
+// 记录收到的选择请求与反馈
+type recordingSelector struct {
+	mu       sync.Mutex
+	servers  []xclient.ServerInfo
+	call     xclient.CallInfo
+	feedback []string //反馈的实例与错误码
+	err      error    //非nil时Select返回该错误
+}
+
+func (s *recordingSelector) Select(_ context.Context, servers []xclient.ServerInfo, call xclient.CallInfo) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.servers, s.call = servers, call
+	if s.err != nil {
+		return "", s.err
+	}
+	return servers[len(servers)-1].Addr, nil
+}
+
+func (s *recordingSelector) Feedback(addr string, _ xclient.CallInfo, d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := "ok"
+	if err != nil {
+		result = GeeRPC.CodeOf(err).String()
+	}
+	s.feedback = append(s.feedback, addr+" "+result)
+}
+
+func TestSetSelector(t *testing.T) {
+	addrs := startServers(t, 2)
+	d := xclient.NewMultiserversDiscovery(addrs)
+	d.SetMetadata(addrs[1], map[string]string{"zone": "b"})
+	xc := xclient.NewXClient(d, xclient.RandomSelect, nil)
+	t.Cleanup(func() { _ = xc.Close() })
+	s := &recordingSelector{}
+	xc.SetSelector(s)
+
+	var reply int
+	if err := xc.Call(context.Background(), "Foo.Sum", [2]int{1, 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("Sum = %d, %v", reply, err)
+	}
+	_ = xc.Call(context.Background(), "Foo.Div", [2]int{1, 0}, &reply)
+	//调用方取消的调用不反馈
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	_ = xc.Call(ctx, "Foo.Sleep", time.Second, &reply)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.servers) != 2 || s.servers[1].Metadata["zone"] != "b" {
+		t.Errorf("servers = %+v, want both instances with metadata", s.servers)
+	}
+	if s.call.ServiceMethod != "Foo.Sleep" || s.call.Args != time.Second {
+		t.Errorf("call = %+v", s.call)
+	}
+	want := []string{addrs[1] + " ok", addrs[1] + " invalid argument"}
+	if fmt.Sprint(s.feedback) != fmt.Sprint(want) {
+		t.Errorf("feedback = %v, want %v", s.feedback, want)
+	}
+}
+
+func TestSelectorErrors(t *testing.T) {
+	xc := newXClient(t, startServers(t, 1))
+	want := errors.New("no instance in zone")
+	xc.SetSelector(&recordingSelector{err: want})
+	var reply int
+	if err := xc.Call(context.Background(), "Foo.Sum", [2]int{1, 2}, &reply); !errors.Is(err, want) {
+		t.Fatalf("err = %v, want the selector error", err)
+	}
+	if _, err := xclient.NewSelector(xclient.SelectMode(100)).Select(context.Background(), serverInfos("a"), xclient.CallInfo{}); err == nil {
+		t.Fatal("unsupported mode selected an instance")
+	}
+}
@@ -4,79 +4,217 @@ import (
 	GeeRPC "codec"
 	"codec/client"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"reflect"
 	"sync"
+	"time"
 )
 
 type XClient struct {
-	d       Discovery
-	mode    SelectMode
-	opt     *GeeRPC.Option
-	mu      sync.Mutex
-	clients map[string]*client.Client
+	d        Discovery
+	selector Selector
+	opt      *GeeRPC.Option
+	mu       sync.Mutex
+	clients  map[string]*connPool
+	pool     *PoolPolicy //连接池策略，nil表示每个实例一条连接
+
+	retry       *RetryPolicy        //重试策略，nil表示使用DefaultRetryPolicy
+	idempotent  map[string]bool     //声明为幂等的方法
+	failMode    FailMode            //默认失败处理模式
+	methodModes map[string]FailMode //按方法配置的失败处理模式
+	hedge       *HedgePolicy        //对冲策略
+	latencies   sync.Map            //方法名 -> *latencyWindow
+
+	breakerPolicy *BreakerPolicy   //熔断策略，nil表示不熔断
+	breakers      sync.Map         //地址 -> *breaker
+	outliers      *outlierDetector //异常实例检测，nil表示不检测
+	health        *healthChecker   //主动健康检查，nil表示不检查
 }
 
 var _ io.Closer = (*XClient)(nil)
 
+// 初始化负载均衡客户端
+func NewXClient(d Discovery, mode SelectMode, opt *GeeRPC.Option) *XClient {
+	return &XClient{
+		d:           d,
+		selector:    NewSelector(mode),
+		opt:         opt,
+		clients:     make(map[string]*connPool),
+		idempotent:  make(map[string]bool),
+		failMode:    Failover,
+		methodModes: make(map[string]FailMode),
+	}
+}
+
 // 关闭负载均衡客户端
 func (xc *XClient) Close() error {
 	xc.mu.Lock()
 	defer xc.mu.Unlock()
-	for key, client := range xc.clients {
-		_ = client.Close()
+	if xc.health != nil {
+		close(xc.health.done)
+		xc.health = nil
+	}
+	for key, pool := range xc.clients {
+		pool.close()
 		delete(xc.clients, key)
 	}
 	return nil
 }
 
+// 从实例的连接池中取出未完成请求最少的连接，拨号不持有xc.mu
 func (xc *XClient) Dial(rpcAddr string) (*client.Client, error) {
 	xc.mu.Lock()
-	defer xc.mu.Unlock()
-	clientOne, ok := xc.clients[rpcAddr]
-	if ok && !clientOne.IsAvailable() {
-		_ = clientOne.Close()
-		delete(xc.clients, rpcAddr)
-		clientOne = nil
-	}
-	if clientOne == nil {
-		var err error
-		clientOne, err = client.XDial(rpcAddr, xc.opt)
-		if err != nil {
-			return nil, err
-		}
-		xc.clients[rpcAddr] = clientOne
+	pool, ok := xc.clients[rpcAddr]
+	if !ok {
+		pool = &connPool{}
+		xc.clients[rpcAddr] = pool
+	}
+	policy, opt := xc.pool, xc.opt
+	xc.mu.Unlock()
+	return pool.get(policy, func() (*client.Client, error) {
+		return client.XDial(rpcAddr, opt)
+	})
+}
+
+// 把调用结果反馈给熔断器、异常检测与选择器，调用方主动取消的调用不计入
+func (xc *XClient) feedback(ctx context.Context, rpcAddr, serviceMethod string, args interface{}, d time.Duration, err error) {
+	xc.recordBreaker(ctx, rpcAddr, err)
+	xc.report(ctx, rpcAddr, serviceMethod, args, d, err)
+}
+
+// 记录熔断器结果，与breaker.allow一一对应；调用方主动取消时归还探测名额
+func (xc *XClient) recordBreaker(ctx context.Context, rpcAddr string, err error) {
+	p := xc.getBreakerPolicy()
+	if p == nil {
+		return
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		xc.breakerFor(rpcAddr).release()
+		return
 	}
-	return clientOne, nil
+	xc.breakerFor(rpcAddr).record(p, err != nil && unhealthy(err), time.Now())
+}
+
+// 把单个调用的结果反馈给异常检测与选择器
+func (xc *XClient) report(ctx context.Context, rpcAddr, serviceMethod string, args interface{}, d time.Duration, err error) {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return
+	}
+	if o := xc.getOutliers(); o != nil {
+		o.record(rpcAddr, d, err != nil && unhealthy(err), time.Now())
+	}
+	xc.getSelector().Feedback(rpcAddr, CallInfo{ServiceMethod: serviceMethod, Args: args}, d, err)
 }
 
 func (xc *XClient) call(rpcAddr string, ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	if p := xc.getBreakerPolicy(); p != nil && !xc.breakerFor(rpcAddr).allow(p, time.Now()) {
+		return fmt.Errorf("%w: %s", ErrBreakerOpen, rpcAddr)
+	}
 	client, err := xc.Dial(rpcAddr)
+	if err != nil {
+		err = &dialError{addr: rpcAddr, err: err}
+		xc.feedback(ctx, rpcAddr, serviceMethod, args, 0, err)
+		return err
+	}
+	start := time.Now()
+	err = client.Call(ctx, serviceMethod, args, reply)
+	xc.feedback(ctx, rpcAddr, serviceMethod, args, time.Since(start), err)
+	if err == nil {
+		xc.observe(serviceMethod, time.Since(start))
+	}
+	return err
+}
+
+// 批量调用，按第一个请求选择实例，所有请求通过同一连接一次性写出
+// 每个请求的错误写入call.Error，不重试
+func (xc *XClient) Batch(ctx context.Context, calls []*client.Call) error {
+	return xc.batch(ctx, calls, (*client.Client).Batch)
+}
+
+// 合并批量调用，按第一个请求选择实例，所有请求放在一个请求帧中由服务端并行执行
+func (xc *XClient) CallBatch(ctx context.Context, calls []*client.Call) error {
+	return xc.batch(ctx, calls, (*client.Client).CallBatch)
+}
+
+func (xc *XClient) batch(ctx context.Context, calls []*client.Call, do func(*client.Client, context.Context, []*client.Call) error) error {
+	if len(calls) == 0 {
+		return nil
+	}
+	rpcAddr, err := xc.selectServer(ctx, calls[0].ServiceMethod, calls[0].Args)
+	if err != nil {
+		return failCalls(calls, err)
+	}
+	if p := xc.getBreakerPolicy(); p != nil && !xc.breakerFor(rpcAddr).allow(p, time.Now()) {
+		return failCalls(calls, fmt.Errorf("%w: %s", ErrBreakerOpen, rpcAddr))
+	}
+	c, err := xc.Dial(rpcAddr)
+	if err != nil {
+		err = &dialError{addr: rpcAddr, err: err}
+		xc.feedback(ctx, rpcAddr, calls[0].ServiceMethod, calls[0].Args, 0, err)
+		return failCalls(calls, err)
+	}
+	start := time.Now()
+	err = do(c, ctx, calls)
+	//整个批量请求只获取了一次熔断器许可，只记录一次结果，任一请求不健康即视为失败
+	batchErr := err
+	for _, call := range calls {
+		xc.report(ctx, rpcAddr, call.ServiceMethod, call.Args, time.Since(start), call.Error)
+		if batchErr == nil && call.Error != nil && unhealthy(call.Error) {
+			batchErr = call.Error
+		}
+	}
+	xc.recordBreaker(ctx, rpcAddr, batchErr)
+	return err
+}
+
+// 批量请求整体失败，每个请求都以该错误结束
+func failCalls(calls []*client.Call, err error) error {
+	for _, call := range calls {
+		call.Error = err
+	}
+	return err
+}
+
+// 单向调用，按选择策略选出实例后写出请求即返回，不重试
+func (xc *XClient) Notify(ctx context.Context, serviceMethod string, args interface{}) error {
+	rpcAddr, err := xc.selectServer(ctx, serviceMethod, args)
 	if err != nil {
 		return err
 	}
-	return client.Call(ctx, serviceMethod, args, reply)
+	c, err := xc.Dial(rpcAddr)
+	if err != nil {
+		return &dialError{addr: rpcAddr, err: err}
+	}
+	return c.Notify(ctx, serviceMethod, args)
 }
 
+// 按选择策略调用一个实例，失败时按失败处理模式与重试策略重试
 func (xc *XClient) Call(ctx context.Context, serviceMethod string, args, reply interface{}) error {
-	rpcAddr, err := xc.d.Get(xc.mode)
+	rpcAddr, err := xc.selectServer(ctx, serviceMethod, args)
 	if err != nil {
 		return err
 	}
-	return xc.call(rpcAddr, ctx, serviceMethod, args, reply)
+	policy, mode := xc.retryFor(serviceMethod)
+	if mode == Failfast {
+		return xc.call(rpcAddr, ctx, serviceMethod, args, reply)
+	}
+	return xc.callWithRetry(policy, mode, rpcAddr, ctx, serviceMethod, args, reply)
 }
 
 // 广播功能
 func (xc *XClient) Broadcast(ctx context.Context, serviceMethod string, args, reply interface{}) error {
 	servers, err := xc.d.GetAll()
 	if err != nil {
-		return nil
+		return err
 	}
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var e error
 	replyDone := reply == nil
 	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	for _, rpcAddr := range servers {
 		wg.Add(1)
 		go func(rpcAddr string) {
@@ -101,3 +239,85 @@ func (xc *XClient) Broadcast(ctx context.Context, serviceMethod string, args, re
 	wg.Wait()
 	return e
 }
+
+// 单个实例的广播结果
+type BroadcastResult struct {
+	Reply interface{} //与reply同类型的指针，调用失败时为nil
+	Err   error
+}
+
+// 广播到所有实例并等待全部返回，reply只用于确定响应类型
+// 返回地址到结果的映射，只有服务发现失败时返回error
+func (xc *XClient) BroadcastAll(ctx context.Context, serviceMethod string, args, reply interface{}) (map[string]*BroadcastResult, error) {
+	servers, err := xc.d.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return xc.broadcastAll(ctx, servers, serviceMethod, args, reply), nil
+}
+
+func (xc *XClient) broadcastAll(ctx context.Context, servers []string, serviceMethod string, args, reply interface{}) map[string]*BroadcastResult {
+	results := make(map[string]*BroadcastResult, len(servers))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, rpcAddr := range servers {
+		wg.Add(1)
+		go func(rpcAddr string) {
+			defer wg.Done()
+			var clonedReply interface{}
+			if reply != nil {
+				clonedReply = reflect.New(reflect.ValueOf(reply).Elem().Type()).Interface()
+			}
+			err := xc.call(rpcAddr, ctx, serviceMethod, args, clonedReply)
+			r := &BroadcastResult{Err: err}
+			if err == nil {
+				r.Reply = clonedReply
+			}
+			mu.Lock()
+			results[rpcAddr] = r
+			mu.Unlock()
+		}(rpcAddr)
+	}
+	wg.Wait()
+	return results
+}
+
+var (
+	ErrQuorumNotReached = errors.New("rpc xclient: quorum not reached")
+	ErrInvalidQuorum    = errors.New("rpc xclient: invalid quorum")
+)
+
+// 广播到所有实例，至少quorum个实例成功时返回nil，并把其中一个成功的响应写入reply
+// 无论成败都返回每个实例的结果，便于确认哪些实例已确认
+// quorum小于1或大于实例数时不发起调用，返回ErrInvalidQuorum
+func (xc *XClient) BroadcastQuorum(ctx context.Context, serviceMethod string, args, reply interface{}, quorum int) (map[string]*BroadcastResult, error) {
+	servers, err := xc.d.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if quorum < 1 || quorum > len(servers) {
+		return nil, fmt.Errorf("%w: %d of %d servers", ErrInvalidQuorum, quorum, len(servers))
+	}
+	results := xc.broadcastAll(ctx, servers, serviceMethod, args, reply)
+	succeeded := 0
+	var firstErr error
+	for _, r := range results {
+		if r.Err != nil {
+			if firstErr == nil {
+				firstErr = r.Err
+			}
+			continue
+		}
+		if succeeded == 0 && reply != nil {
+			reflect.ValueOf(reply).Elem().Set(reflect.ValueOf(r.Reply).Elem())
+		}
+		succeeded++
+	}
+	if succeeded < quorum {
+		if firstErr != nil {
+			return results, fmt.Errorf("%w: %d/%d succeeded, need %d: %v", ErrQuorumNotReached, succeeded, len(results), quorum, firstErr)
+		}
+		return results, fmt.Errorf("%w: %d/%d succeeded, need %d", ErrQuorumNotReached, succeeded, len(results), quorum)
+	}
+	return results, nil
+}
@@ -0,0 +1,326 @@
+package xclient_test
+
+import (
+	GeeRPC "codec"
+	"codec/client"
+	"codec/xclient"
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type Foo struct {
+	delay time.Duration //Sum返回前等待的时间
+}
+
+func (f Foo) Sum(args [2]int, reply *int) error {
+	time.Sleep(f.delay)
+	*reply = args[0] + args[1]
+	return nil
+}
+
+func (Foo) Sleep(d time.Duration, reply *int) error {
+	time.Sleep(d)
+	return nil
+}
+
+var divCalls int64 //Foo.Div被调用的次数
+
+func (Foo) Div(args [2]int, reply *int) error {
+	atomic.AddInt64(&divCalls, 1)
+	if args[1] == 0 {
+		return GeeRPC.Errorf(GeeRPC.CodeInvalidArgument, "divide by zero")
+	}
+	*reply = args[0] / args[1]
+	return nil
+}
+
+// Foo.Fail返回的错误
+type FailArgs struct {
+	Code       GeeRPC.Code
+	RetryAfter time.Duration
+}
+
+var failCalls int64 //Foo.Fail被调用的次数
+
+func (Foo) Fail(args FailArgs, reply *int) error {
+	atomic.AddInt64(&failCalls, 1)
+	err := GeeRPC.Errorf(args.Code, "fail with %s", args.Code)
+	err.RetryAfter = args.RetryAfter
+	return err
+}
+
+// 启动n个服务端，返回带协议前缀的地址
+func startServers(t *testing.T, n int) []string {
+	t.Helper()
+	addrs := make([]string, n)
+	for i := range addrs {
+		addrs[i] = startServer(t, Foo{})
+	}
+	return addrs
+}
+
+// 启动注册了foo的服务端，返回带协议前缀的地址
+func startServer(t *testing.T, foo Foo) string {
+	t.Helper()
+	server := GeeRPC.NewServer()
+	if err := server.Register(foo); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go server.Accept(l)
+	return "tcp@" + l.Addr().String()
+}
+
+func newXClient(t *testing.T, addrs []string) *xclient.XClient {
+	t.Helper()
+	xc := xclient.NewXClient(xclient.NewMultiserversDiscovery(addrs), xclient.RoundRobinSelect, nil)
+	t.Cleanup(func() { _ = xc.Close() })
+	return xc
+}
+
+// 整个批量请求超时时每个请求都以该错误结束，并计入熔断统计
+func TestCallBatchFailureFeedsBreaker(t *testing.T) {
+	addrs := startServers(t, 1)
+	xc := newXClient(t, addrs)
+	xc.SetBreakerPolicy(&xclient.BreakerPolicy{FailureRatio: 1, MinRequests: 1, Window: time.Minute, Cooldown: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	calls := []*client.Call{
+		{ServiceMethod: "Foo.Sleep", Args: 200 * time.Millisecond, Reply: new(int)},
+		{ServiceMethod: "Foo.Sum", Args: [2]int{1, 2}, Reply: new(int)},
+	}
+	err := xc.CallBatch(ctx, calls)
+	if GeeRPC.CodeOf(err) != GeeRPC.CodeTimeout {
+		t.Fatalf("CallBatch err = %v, want timeout", err)
+	}
+	for i, call := range calls {
+		if call.Error != err {
+			t.Errorf("calls[%d].Error = %v, want %v", i, call.Error, err)
+		}
+	}
+	if s := xc.BreakerState(addrs[0]); s != xclient.BreakerOpen {
+		t.Fatalf("breaker = %s, want open", s)
+	}
+}
+
+// 返回一个已关闭的地址，连接会被拒绝
+func deadAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := "tcp@" + l.Addr().String()
+	_ = l.Close()
+	return addr
+}
+
+// 总是选择列表中的第一个实例
+type firstSelector struct{}
+
+func (firstSelector) Select(_ context.Context, servers []xclient.ServerInfo, _ xclient.CallInfo) (string, error) {
+	return servers[0].Addr, nil
+}
+
+func (firstSelector) Feedback(string, xclient.CallInfo, time.Duration, error) {}
+
+// 失败处理模式不依赖重试策略
+func TestFailModesWithoutRetryPolicy(t *testing.T) {
+	live := startServers(t, 1)[0]
+	servers := []string{deadAddr(t), deadAddr(t), deadAddr(t), live}
+	tests := []struct {
+		mode    xclient.FailMode
+		policy  *xclient.RetryPolicy
+		wantErr bool
+	}{
+		{xclient.Failover, nil, false},                        //依次尝试直到存活的实例
+		{xclient.Failover, xclient.DefaultRetryPolicy, false}, //不受MaxAttempts限制
+		{xclient.Failfast, nil, true},                         //只调用第一个失效实例
+		{xclient.Failtry, nil, true},                          //在同一失效实例上重试
+	}
+	for _, tt := range tests {
+		xc := newXClient(t, servers)
+		xc.SetSelector(firstSelector{})
+		xc.SetFailMode(tt.mode)
+		xc.SetRetryPolicy(tt.policy)
+		var reply int
+		err := xc.Call(context.Background(), "Foo.Sum", [2]int{1, 2}, &reply)
+		if (err != nil) != tt.wantErr || (err == nil && reply != 3) {
+			t.Errorf("mode %d: reply = %d, err = %v; wantErr %v", tt.mode, reply, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBroadcastQuorum(t *testing.T) {
+	servers := append(startServers(t, 2), deadAddr(t))
+	tests := []struct {
+		quorum int
+		want   error
+	}{
+		{-1, xclient.ErrInvalidQuorum},
+		{0, xclient.ErrInvalidQuorum},
+		{1, nil},
+		{2, nil},
+		{3, xclient.ErrQuorumNotReached},
+		{4, xclient.ErrInvalidQuorum},
+	}
+	xc := newXClient(t, servers)
+	for _, tt := range tests {
+		var reply int
+		results, err := xc.BroadcastQuorum(context.Background(), "Foo.Sum", [2]int{1, 2}, &reply, tt.quorum)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("quorum %d: err = %v, want %v", tt.quorum, err, tt.want)
+			continue
+		}
+		if errors.Is(err, xclient.ErrInvalidQuorum) {
+			if results != nil {
+				t.Errorf("quorum %d: broadcast sent despite invalid quorum", tt.quorum)
+			}
+			continue
+		}
+		if len(results) != len(servers) {
+			t.Errorf("quorum %d: %d results, want %d", tt.quorum, len(results), len(servers))
+		}
+	}
+}
+
+// 总是选择列表中的最后一个实例
+type lastSelector struct{ firstSelector }
+
+func (lastSelector) Select(_ context.Context, servers []xclient.ServerInfo, _ xclient.CallInfo) (string, error) {
+	return servers[len(servers)-1].Addr, nil
+}
+
+// 接受连接但从不响应，HTTP拨号会一直等待到ConnectTimeout
+func blackholeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+		}
+	}()
+	return "http@" + l.Addr().String()
+}
+
+// 一个实例拨号卡住时不影响其他实例的调用
+func TestSlowDialDoesNotBlockOtherServers(t *testing.T) {
+	blackhole, live := blackholeAddr(t), startServers(t, 1)[0]
+	xc := xclient.NewXClient(xclient.NewMultiserversDiscovery([]string{blackhole, live}), xclient.RandomSelect,
+		&GeeRPC.Option{ConnectTimeout: time.Second})
+	t.Cleanup(func() { _ = xc.Close() })
+	xc.SetSelector(lastSelector{})
+	dialed := make(chan error, 1)
+	go func() {
+		_, err := xc.Dial(blackhole)
+		dialed <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	var reply int
+	if err := xc.Call(context.Background(), "Foo.Sum", [2]int{1, 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("Call = %d, %v", reply, err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("Call took %s while another server was dialing", d)
+	}
+	_ = xc.Stats()
+	if err := <-dialed; err == nil {
+		t.Fatal("dial to blackhole succeeded")
+	}
+}
+
+// 健康检查使用自己的连接，不占用连接池
+func TestHealthCheckBypassesPool(t *testing.T) {
+	servers := append(startServers(t, 1), deadAddr(t))
+	xc := newXClient(t, servers)
+	xc.SetHealthCheck(&xclient.HealthCheckPolicy{Interval: 20 * time.Millisecond, Timeout: 500 * time.Millisecond})
+	deadline := time.Now().Add(2 * time.Second)
+	for xc.ServingStatus(servers[0]) != GeeRPC.Serving || xc.ServingStatus(servers[1]) != GeeRPC.NotServing {
+		if time.Now().After(deadline) {
+			t.Fatalf("status = %s, %s; want SERVING, NOT_SERVING", xc.ServingStatus(servers[0]), xc.ServingStatus(servers[1]))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats := xc.Stats(); len(stats) != 0 {
+		t.Fatalf("health checks opened pooled connections: %v", stats)
+	}
+}
+
+// 未设置间隔与超时的策略使用默认值，不应panic或把所有实例判为不健康
+func TestHealthCheckPolicyDefaults(t *testing.T) {
+	servers := startServers(t, 1)
+	xc := newXClient(t, servers)
+	xc.SetHealthCheck(&xclient.HealthCheckPolicy{})
+	deadline := time.Now().Add(2 * time.Second)
+	for xc.ServingStatus(servers[0]) != GeeRPC.Serving {
+		if time.Now().After(deadline) {
+			t.Fatalf("status = %s, want SERVING", xc.ServingStatus(servers[0]))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// 批量请求只获取一次熔断器许可，只记录一次结果
+func TestBatchRecordsOneBreakerResult(t *testing.T) {
+	addrs := startServers(t, 1)
+	xc := newXClient(t, addrs)
+	xc.SetBreakerPolicy(&xclient.BreakerPolicy{FailureRatio: 1, MinRequests: 2, Window: time.Minute, Cooldown: time.Minute})
+	for i, want := range []xclient.BreakerState{xclient.BreakerClosed, xclient.BreakerOpen} {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		calls := make([]*client.Call, 3)
+		for j := range calls {
+			calls[j] = &client.Call{ServiceMethod: "Foo.Sleep", Args: 200 * time.Millisecond, Reply: new(int)}
+		}
+		_ = xc.Batch(ctx, calls)
+		cancel()
+		if s := xc.BreakerState(addrs[0]); s != want {
+			t.Fatalf("after batch %d: breaker = %s, want %s", i+1, s, want)
+		}
+	}
+}
+
+// 按实例统计调用结果
+func TestXClientStats(t *testing.T) {
+	addrs := startServers(t, 2)
+	xc := newXClient(t, addrs)
+	ctx := context.Background()
+	var reply int
+	for i := 0; i < 4; i++ {
+		if err := xc.Call(ctx, "Foo.Sum", [2]int{1, 2}, &reply); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = xc.Call(ctx, "Foo.Div", [2]int{1, 0}, &reply)
+	stats := xc.Stats()
+	var completed, failed uint64
+	for _, addr := range addrs {
+		s, ok := stats[addr]
+		if !ok || s.State != client.StateReady || s.Pending != 0 || s.BytesSent == 0 {
+			t.Fatalf("stats[%s] = %+v", addr, s)
+		}
+		completed += s.Completed
+		failed += s.Failed
+	}
+	if completed != 4 || failed != 1 {
+		t.Fatalf("completed = %d, failed = %d; want 4, 1", completed, failed)
+	}
+}